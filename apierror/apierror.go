@@ -0,0 +1,50 @@
+// Package apierror contains the JSON error envelope of the HTTP API.
+package apierror
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// Error is the JSON error envelope of the HTTP API. HTTP API endpoints
+// respond with it when a request fails, and httpclient returns it as the error.
+//
+// It matches os.ErrNotExist if Code is 404 Not Found, and os.ErrPermission
+// if Code is 403 Forbidden, so errors.Is can be used to classify it the same
+// way notificationsapp does on the server side. os.IsNotExist and os.IsPermission
+// don't recognize it, since they only unwrap errors from package os.
+type Error struct {
+	Code    int    `json:"code"`            // HTTP status code.
+	Message string `json:"message"`         // Message describing the error.
+	Field   string `json:"field,omitempty"` // Request parameter that the error is about, if any.
+}
+
+func (e *Error) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("%d %s: %s: %s", e.Code, http.StatusText(e.Code), e.Field, e.Message)
+	}
+	return fmt.Sprintf("%d %s: %s", e.Code, http.StatusText(e.Code), e.Message)
+}
+
+// Is reports whether e matches target. See Error.
+func (e *Error) Is(target error) bool {
+	switch e.Code {
+	case http.StatusNotFound:
+		return target == os.ErrNotExist
+	case http.StatusForbidden:
+		return target == os.ErrPermission
+	default:
+		return false
+	}
+}
+
+// FieldError is an error about request parameter Field. HTTP API endpoints
+// that fail because of it report Field in the error envelope.
+type FieldError struct {
+	Field string
+	Err   error // Not nil.
+}
+
+func (e FieldError) Error() string { return e.Err.Error() }
+func (e FieldError) Unwrap() error { return e.Err }
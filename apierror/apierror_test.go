@@ -0,0 +1,36 @@
+package apierror_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/shurcooL/notificationsapp/apierror"
+)
+
+func TestErrorIs(t *testing.T) {
+	tests := []struct {
+		code           int
+		wantNotExist   bool
+		wantPermission bool
+	}{
+		{400, false, false},
+		{403, false, true},
+		{404, true, false},
+		{500, false, false},
+	}
+	for _, tc := range tests {
+		err := fmt.Errorf("MarkRead: %w", &apierror.Error{Code: tc.code, Message: "m"})
+		if got := errors.Is(err, os.ErrNotExist); got != tc.wantNotExist {
+			t.Errorf("%d: errors.Is(err, os.ErrNotExist): got %v, want %v", tc.code, got, tc.wantNotExist)
+		}
+		if got := errors.Is(err, os.ErrPermission); got != tc.wantPermission {
+			t.Errorf("%d: errors.Is(err, os.ErrPermission): got %v, want %v", tc.code, got, tc.wantPermission)
+		}
+		var e *apierror.Error
+		if !errors.As(err, &e) || e.Code != tc.code {
+			t.Errorf("%d: errors.As: got %v", tc.code, e)
+		}
+	}
+}
@@ -11,11 +11,14 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/shurcooL/home/httputil"
 	"github.com/shurcooL/notifications"
 	"github.com/shurcooL/notificationsapp"
 	"github.com/shurcooL/notificationsapp/httphandler"
-	"github.com/shurcooL/notificationsapp/httproute"
+	"github.com/shurcooL/notificationsapp/metrics"
+	"github.com/shurcooL/notificationsapp/mute"
+	"github.com/shurcooL/notificationsapp/snooze"
+	"github.com/shurcooL/notificationsapp/stream"
+	"github.com/shurcooL/notificationsapp/undo"
 	"github.com/shurcooL/users"
 )
 
@@ -34,14 +37,8 @@ func main() {
 
 func run() error {
 	users := mockUsers{}
-	service := mockNotifications{}
-
-	// Register HTTP API endpoints.
-	apiHandler := httphandler.Notifications{Notifications: service}
-	http.Handle(httproute.List, httputil.ErrorHandler(users, apiHandler.List))
-	http.Handle(httproute.Count, httputil.ErrorHandler(users, apiHandler.Count))
-	http.Handle(httproute.MarkRead, httputil.ErrorHandler(users, apiHandler.MarkRead))
-	http.Handle(httproute.MarkAllRead, httputil.ErrorHandler(users, apiHandler.MarkAllRead))
+	events := stream.NewHub(users)
+	service := undo.NewService(stream.NewService(snooze.NewService(mute.NewService(mockNotifications{}, users), users), events), users)
 
 	opt := notificationsapp.Options{
 		HeadPre: `<title>Notifications</title>
@@ -58,12 +55,12 @@ func run() error {
 </style>`,
 	}
 	opt.BodyPre = `<div style="max-width: 800px; margin: 0 auto 100px auto;">`
-	notificationsApp := notificationsapp.New(service, users, opt)
+	opt.Metrics = metrics.New()
+	http.Handle("/metrics", opt.Metrics)
 
-	http.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
-		req = req.WithContext(context.WithValue(req.Context(), notificationsapp.BaseURIContextKey, ".")) // TODO: Confirm "." vs "/" vs "".
-		notificationsApp.ServeHTTP(w, req)
-	})
+	// Register the app and its HTTP API endpoints.
+	apiHandler := httphandler.Notifications{Notifications: service, Events: events}
+	notificationsapp.Mount(http.DefaultServeMux, "", apiHandler, users, opt)
 
 	log.Println("Started.")
 
@@ -128,6 +125,12 @@ func (mockNotifications) MarkAllRead(ctx context.Context, repo notifications.Rep
 	return nil
 }
 
+func (mockNotifications) MarkUnread(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error {
+	// TODO: Perhaps have it modify what List returns, etc.
+	fmt.Println("MarkUnread:", repo.URI, threadType, threadID)
+	return nil
+}
+
 // ns is a list of mock notifications.
 var ns = func() notifications.Notifications {
 	passed := time.Since(time.Date(1, 1, 1, 0, 0, 63621777703, 945428426, time.UTC))
@@ -0,0 +1,52 @@
+// Package batch contains batch operations on notifications.
+package batch
+
+import (
+	"context"
+
+	"github.com/shurcooL/notifications"
+)
+
+// Thread identifies a thread.
+type Thread struct {
+	RepoURI    string
+	ThreadType string
+	ThreadID   uint64
+}
+
+// Failure is a failure to perform an operation on a thread.
+type Failure struct {
+	Thread
+	Error string // Error message.
+}
+
+// MarkReader is an optional interface that a notifications.Service
+// can implement to mark many threads as read at once natively.
+type MarkReader interface {
+	// MarkReadBatch marks the specified threads as read. It attempts to mark
+	// every thread, and reports the ones it failed to mark as read. The error
+	// is non-nil only if the operation as a whole couldn't be performed.
+	MarkReadBatch(ctx context.Context, threads []Thread) ([]Failure, error)
+}
+
+// MarkRead marks the specified threads in service as read, and reports the ones
+// it failed to mark as read. If service implements MarkReader, it's used to do it
+// natively. Otherwise, each thread is marked as read via service.MarkRead.
+// The returned slice of failures is non-nil.
+func MarkRead(ctx context.Context, service notifications.Service, threads []Thread) ([]Failure, error) {
+	if m, ok := service.(MarkReader); ok {
+		failures, err := m.MarkReadBatch(ctx, threads)
+		if failures == nil && err == nil {
+			failures = []Failure{}
+		}
+		return failures, err
+	}
+	failures := []Failure{}
+	for _, t := range threads {
+		err := service.MarkRead(ctx, notifications.RepoSpec{URI: t.RepoURI}, t.ThreadType, t.ThreadID)
+		if err != nil {
+			failures = append(failures, Failure{Thread: t, Error: err.Error()})
+		}
+	}
+	return failures, nil
+}
@@ -0,0 +1,44 @@
+package batch_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/batch"
+)
+
+func TestMarkRead(t *testing.T) {
+	ms := &mockNotifications{}
+	threads := []batch.Thread{
+		{RepoURI: "github.com/a/b", ThreadType: "Issue", ThreadID: 1},
+		{RepoURI: "github.com/a/b", ThreadType: "Issue", ThreadID: 2},
+		{RepoURI: "github.com/a/c", ThreadType: "PullRequest", ThreadID: 3},
+	}
+	failures, err := batch.MarkRead(context.Background(), ms, threads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []batch.Failure{{Thread: threads[1], Error: "thread 2 not found"}}
+	if !reflect.DeepEqual(failures, want) {
+		t.Errorf("got failures %+v, want %+v", failures, want)
+	}
+	if got, want := ms.read, []uint64{1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got threads marked as read %v, want %v", got, want)
+	}
+}
+
+type mockNotifications struct {
+	notifications.Service
+	read []uint64 // IDs of threads marked as read.
+}
+
+func (m *mockNotifications) MarkRead(_ context.Context, _ notifications.RepoSpec, _ string, threadID uint64) error {
+	if threadID == 2 {
+		return fmt.Errorf("thread %v not found", threadID)
+	}
+	m.read = append(m.read, threadID)
+	return nil
+}
@@ -6,11 +6,15 @@ import (
 	"path"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/dustin/go-humanize"
 	"github.com/shurcooL/htmlg"
 	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/csrf"
+	"github.com/shurcooL/notificationsapp/filter"
+	"github.com/shurcooL/notificationsapp/i18n"
+	"github.com/shurcooL/notificationsapp/snooze"
 	"github.com/shurcooL/octicon"
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
@@ -19,35 +23,33 @@ import (
 // NotificationsByRepo component displays notifications grouped by repos.
 type NotificationsByRepo struct {
 	Notifications notifications.Notifications
+	BaseURI       string       // Base URI of the app. Repository headers link to repository pages under it.
+	Printer       i18n.Printer // Printer for the language of the UI.
+	CSRFToken     string       // CSRF token that action forms are submitted with.
 }
 
 func (a NotificationsByRepo) Render() []*html.Node {
 	// TODO: Make this much nicer.
 	/*
-		{{if .}}{{range .}}
-			{{render .}}
-		{{end}}{{else}}
-			<div class="list-entry-border">
-				<div style="text-align: center; margin-top: 80px; margin-bottom: 80px;">No new notifications.</div>
-			</div>
-		{{end}}
+		<div class="NotificationsByRepo">
+			{{if .}}{{range .}}
+				{{render .}}
+			{{end}}{{else}}
+				<div class="list-entry-border">
+					<div class="NoNotifications" style="text-align: center; margin-top: 80px; margin-bottom: 80px;">No new notifications.</div>
+				</div>
+			{{end}}
+		</div>
 	*/
 	if len(a.Notifications) == 0 {
-		div := &html.Node{
-			Type: html.ElementNode, Data: atom.Div.String(),
-			Attr: []html.Attribute{
-				{Key: atom.Style.String(), Val: "text-align: center; margin-top: 80px; margin-bottom: 80px;"},
-			},
-			FirstChild: htmlg.Text("No new notifications."),
-		}
-		return []*html.Node{htmlg.DivClass("list-entry-border", div)}
+		return []*html.Node{htmlg.DivClass("NotificationsByRepo", htmlg.DivClass("list-entry-border", noNotifications(a.Printer)))}
 	}
 
 	var ns []*html.Node
 	for _, repoNotifications := range a.groupAndSort() {
 		ns = append(ns, repoNotifications.Render()...)
 	}
-	return ns
+	return []*html.Node{htmlg.DivClass("NotificationsByRepo", ns...)}
 }
 
 func (a NotificationsByRepo) groupAndSort() []RepoNotifications {
@@ -59,7 +61,10 @@ func (a NotificationsByRepo) groupAndSort() []RepoNotifications {
 		case nil: // First notification for this RepoSpec.
 			rn := RepoNotifications{
 				Repo:          r,
-				Notifications: []Notification{{Notification: n}},
+				Notifications: []Notification{{Notification: n, Printer: a.Printer, CSRFToken: a.CSRFToken}},
+				BaseURI:       a.BaseURI,
+				Printer:       a.Printer,
+				CSRFToken:     a.CSRFToken,
 				updatedAt:     n.UpdatedAt,
 			}
 			rnm[r] = &rn
@@ -67,7 +72,7 @@ func (a NotificationsByRepo) groupAndSort() []RepoNotifications {
 			if rnp.updatedAt.Before(n.UpdatedAt) {
 				rnp.updatedAt = n.UpdatedAt
 			}
-			rnp.Notifications = append(rnp.Notifications, Notification{Notification: n})
+			rnp.Notifications = append(rnp.Notifications, Notification{Notification: n, Printer: a.Printer, CSRFToken: a.CSRFToken})
 		}
 	}
 
@@ -100,6 +105,9 @@ func (s nByUpdatedAt) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 type RepoNotifications struct {
 	Repo          notifications.RepoSpec
 	Notifications []Notification
+	BaseURI       string       // Base URI of the app. The header links to the repository's notifications page under it.
+	Printer       i18n.Printer // Printer for the language of the UI.
+	CSRFToken     string       // CSRF token that action forms are submitted with.
 
 	updatedAt time.Time // Most recent notification. Used only by NotificationsByRepo.groupAndSort.
 }
@@ -107,13 +115,21 @@ type RepoNotifications struct {
 func (r RepoNotifications) Render() []*html.Node {
 	// TODO: Make this much nicer.
 	/*
-		<div class="RepoNotifications list-entry list-entry-border mark-as-read">
+		<div id="{{RepoAnchor .Repo.URI}}" class="RepoNotifications list-entry list-entry-border mark-as-read" data-repo-uri="{{.Repo.URI}}">
 			<div class="list-entry-header">
 				<span class="content"><a class="black gray-when-read" href="https://{{.Repo.URI}}"><strong>{{.Repo.URI}}</strong></a></span>
-				<span class="right-icon hide-when-read"><a href="javascript:" onclick="MarkAllRead(this, {{.Repo.URI | json}});" title="Mark all {{base .Repo.URI}} notifications as read" style="display: inline-block;"><octicon.Check()></span></a></span>
+				<span class="right-icon"><a href="{{.BaseURI}}/repo/{{.Repo.URI}}" title="Only {{base .Repo.URI}} notifications" style="display: inline-block;"><octicon.Bell()></a></span>
+				<span class="right-icon hide-when-read">
+					<form class="action" method="post" data-action="mark-all-read">
+						<input type="hidden" name="csrf" value="{{.CSRFToken}}"><input type="hidden" name="op" value="mark-all-read"><input type="hidden" name="repo" value="{{.Repo.URI}}">
+						<button type="submit" title="Mark all {{base .Repo.URI}} notifications as read"><octicon.Check()></button>
+					</form>
+				</span>
 			</div>
 			{{range .Notifications}}
 				{{render .}}
+			{{else}}
+				<div class="NoNotifications" style="text-align: center; margin-top: 80px; margin-bottom: 80px;">No new notifications.</div>
 			{{end}}
 		</div>
 	*/
@@ -132,19 +148,29 @@ func (r RepoNotifications) Render() []*html.Node {
 				},
 			},
 		),
-		htmlg.SpanClass("right-icon hide-when-read",
+		htmlg.SpanClass("right-icon",
 			&html.Node{
 				Type: html.ElementNode, Data: atom.A.String(),
 				Attr: []html.Attribute{
-					{Key: atom.Href.String(), Val: "javascript:"},
-					{Key: atom.Onclick.String(), Val: fmt.Sprintf("MarkAllRead(this, %q);", strconv.Quote(r.Repo.URI))},
-					{Key: atom.Title.String(), Val: fmt.Sprintf("Mark all %s notifications as read", path.Base(r.Repo.URI))},
+					{Key: atom.Href.String(), Val: repoURL(r.BaseURI, r.Repo.URI)},
+					{Key: atom.Title.String(), Val: r.Printer.Sprintf("Only %s notifications", path.Base(r.Repo.URI))},
 					{Key: atom.Style.String(), Val: "display: inline-block;"},
 				},
-				FirstChild: octicon.Check(),
+				FirstChild: octicon.Bell(),
 			},
 		),
+		htmlg.SpanClass("right-icon hide-when-read",
+			actionForm(
+				"mark-all-read", r.CSRFToken,
+				r.Printer.Sprintf("Mark all %s notifications as read", path.Base(r.Repo.URI)),
+				octicon.Check(),
+				field{"repo", r.Repo.URI},
+			),
+		),
 	))
+	if len(r.Notifications) == 0 {
+		ns = append(ns, noNotifications(r.Printer))
+	}
 	anyUnread := false
 	for _, notification := range r.Notifications {
 		if !notification.Read {
@@ -157,23 +183,92 @@ func (r RepoNotifications) Render() []*html.Node {
 		divClass += " read"
 	}
 	div := htmlg.DivClass(divClass, ns...)
+	div.Attr = append(div.Attr,
+		html.Attribute{Key: atom.Id.String(), Val: RepoAnchor(r.Repo.URI)},
+		html.Attribute{Key: "data-repo-uri", Val: r.Repo.URI},
+	)
 	return []*html.Node{div}
 }
 
+// repoURL returns the URL of the notifications page of repository repoURI,
+// in the app at base URI baseURI.
+func repoURL(baseURI, repoURI string) string {
+	return strings.TrimSuffix(baseURI, "/") + "/repo/" + repoURI
+}
+
+// RepoAnchor returns the ID of the RepoNotifications element of repository repoURI,
+// for use as a URL fragment.
+func RepoAnchor(repoURI string) string {
+	return "repo-" + repoURI
+}
+
+// LoadMore component displays a control for loading the next page of notifications.
+// It displays nothing if there are no more pages.
+type LoadMore struct {
+	Repo     *notifications.RepoSpec // Repo whose notifications are displayed, or nil for all repos.
+	All      bool                    // Whether read notifications are being displayed.
+	Filter   filter.Options          // Filter that notifications are matching.
+	PageSize int                     // Maximum number of notifications in a page.
+	Cursor   string                  // Cursor of the next page, or empty if there are no more pages.
+	Printer  i18n.Printer            // Printer for the language of the UI.
+}
+
+func (l LoadMore) Render() []*html.Node {
+	// TODO: Make this much nicer.
+	/*
+		{{if .Cursor}}
+			<div class="LoadMore list-entry-border">
+				<a class="black" href="?{{query}}&cursor={{.Cursor}}" data-action="load-more" data-repo-uri="{{.Repo.URI}}" data-page-size="{{.PageSize}}">Load more</a>
+			</div>
+		{{end}}
+	*/
+	if l.Cursor == "" {
+		return nil
+	}
+	v := values(l.All, l.Filter)
+	v.Set("cursor", l.Cursor)
+	a := htmlg.A(l.Printer.Text("Load more"), "?"+v.Encode())
+	a.Attr = append(a.Attr,
+		html.Attribute{Key: atom.Class.String(), Val: "black"},
+		html.Attribute{Key: "data-action", Val: "load-more"},
+		html.Attribute{Key: "data-page-size", Val: strconv.Itoa(l.PageSize)},
+	)
+	if l.Repo != nil {
+		a.Attr = append(a.Attr, html.Attribute{Key: "data-repo-uri", Val: l.Repo.URI})
+	}
+	return []*html.Node{htmlg.DivClass("LoadMore list-entry-border", a)}
+}
+
+// noNotifications returns a placeholder to display when there are no notifications,
+// in the language of printer p.
+func noNotifications(p i18n.Printer) *html.Node {
+	return &html.Node{
+		Type: html.ElementNode, Data: atom.Div.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Class.String(), Val: "NoNotifications"},
+			{Key: atom.Style.String(), Val: "text-align: center; margin-top: 80px; margin-bottom: 80px;"},
+		},
+		FirstChild: htmlg.Text(p.Text("No new notifications.")),
+	}
+}
+
 // Notification component for display purposes.
 type Notification struct {
 	notifications.Notification
+	Printer   i18n.Printer // Printer for the language of the UI.
+	CSRFToken string       // CSRF token that action forms are submitted with.
 }
 
 func (n Notification) Render() []*html.Node {
 	// TODO: Make this much nicer.
 	/*
-		<div class="list-entry-body multilist-entry mark-as-read"{{if .Participating}} style="..."{{end}}>
+		<div class="list-entry-body multilist-entry mark-as-read" data-repo-uri="{{.RepoSpec.URI}}" data-thread-type="{{.ThreadType}}" data-thread-id="{{.ThreadID}}"{{if .Participating}} style="..."{{end}}>
+			<span class="select hide-when-read"><input type="checkbox" class="SelectNotification" title="Select"></span>
 			<span class="content">
 				<table style="width: 100%;">
 				<tr>
 				<td class="notification" style="width: 70%;">
-					<a class="black gray-when-read" onclick="MarkRead(this, {{`` | json}}, {{`` | json}}, 0);" href="{{.HTMLURL}}">
+					<a class="black gray-when-read" data-action="open" href="{{.HTMLURL}}">
 						<span class="fade-when-read" style="color: {{.Color.HexString}}; margin-right: 6px; vertical-align: top;"><octicon.Icon(.Icon)></span>
 						{{.Title}}
 					</a>
@@ -185,14 +280,23 @@ func (n Notification) Render() []*html.Node {
 				</tr>
 				</table>
 			</span>
-			<span class="right-icon hide-when-read"><a href="javascript:" onclick="MarkRead(this, {{.RepoSpec.URI | json}}, {{.ThreadType | json}}, {{.ThreadID}});" title="Mark as read" style="display: inline-block;"><octicon.Check()>"</a></span>
+			<span class="right-icon">{{render (SnoozeMenu .)}}</span>
+			<span class="right-icon"><a href="#" data-action="mute" title="Mute thread" style="display: inline-block;"><octicon.Mute()></a></span>
+			<span class="right-icon show-when-read"><a href="#" data-action="mark-unread" title="Mark as unread" style="display: inline-block;"><octicon.Mail()></a></span>
+			<span class="right-icon hide-when-read">
+				<form class="action" method="post" data-action="mark-read">
+					<input type="hidden" name="csrf" value="{{.CSRFToken}}"><input type="hidden" name="op" value="mark-read"><input type="hidden" name="repo" value="{{.RepoSpec.URI}}">
+					<input type="hidden" name="type" value="{{.ThreadType}}"><input type="hidden" name="id" value="{{.ThreadID}}">
+					<button type="submit" title="Mark as read"><octicon.Check()></button>
+				</form>
+			</span>
 		</div>
 	*/
 	a := &html.Node{
 		Type: html.ElementNode, Data: atom.A.String(),
 		Attr: []html.Attribute{
 			{Key: atom.Class.String(), Val: "black gray-when-read"},
-			{Key: atom.Onclick.String(), Val: `MarkRead(this, '""', '""', 0);`},
+			{Key: "data-action", Val: "open"},
 			{Key: atom.Href.String(), Val: n.HTMLURL},
 		},
 	}
@@ -218,7 +322,7 @@ func (n Notification) Render() []*html.Node {
 			},
 		})
 	}
-	td2.AppendChild(htmlg.SpanClass("tiny gray-when-read", Time{n.UpdatedAt}.Render()...))
+	td2.AppendChild(htmlg.SpanClass("tiny gray-when-read", Time{Time: n.UpdatedAt, Printer: n.Printer}.Render()...))
 	tr := htmlg.TR(td1, td2)
 	table := &html.Node{
 		Type: html.ElementNode, Data: atom.Table.String(),
@@ -228,23 +332,61 @@ func (n Notification) Render() []*html.Node {
 		FirstChild: tr,
 	}
 	span1 := htmlg.SpanClass("content", table)
-	span2 := htmlg.SpanClass("right-icon hide-when-read",
+	snooze := htmlg.SpanClass("right-icon", SnoozeMenu{Notification: n.Notification, Printer: n.Printer}.Render()...)
+	mute := htmlg.SpanClass("right-icon",
 		&html.Node{
 			Type: html.ElementNode, Data: atom.A.String(),
 			Attr: []html.Attribute{
-				{Key: atom.Href.String(), Val: "javascript:"},
-				{Key: atom.Onclick.String(), Val: fmt.Sprintf("MarkRead(this, %q, %q, %v);", strconv.Quote(n.RepoSpec.URI), strconv.Quote(n.ThreadType), n.ThreadID)},
-				{Key: atom.Title.String(), Val: "Mark as read"},
+				{Key: atom.Href.String(), Val: "#"},
+				{Key: "data-action", Val: "mute"},
+				{Key: atom.Title.String(), Val: n.Printer.Text("Mute thread")},
 				{Key: atom.Style.String(), Val: "display: inline-block;"},
 			},
-			FirstChild: octicon.Check(),
+			FirstChild: octicon.Mute(),
+		},
+	)
+	span2 := htmlg.SpanClass("right-icon show-when-read",
+		&html.Node{
+			Type: html.ElementNode, Data: atom.A.String(),
+			Attr: []html.Attribute{
+				{Key: atom.Href.String(), Val: "#"},
+				{Key: "data-action", Val: "mark-unread"},
+				{Key: atom.Title.String(), Val: n.Printer.Text("Mark as unread")},
+				{Key: atom.Style.String(), Val: "display: inline-block;"},
+			},
+			FirstChild: octicon.Mail(),
+		},
+	)
+	span3 := htmlg.SpanClass("right-icon hide-when-read",
+		actionForm(
+			"mark-read", n.CSRFToken,
+			n.Printer.Text("Mark as read"),
+			octicon.Check(),
+			field{"repo", n.RepoSpec.URI},
+			field{"type", n.ThreadType},
+			field{"id", strconv.FormatUint(n.ThreadID, 10)},
+		),
+	)
+	sel := htmlg.SpanClass("select hide-when-read",
+		&html.Node{
+			Type: html.ElementNode, Data: atom.Input.String(),
+			Attr: []html.Attribute{
+				{Key: atom.Type.String(), Val: "checkbox"},
+				{Key: atom.Class.String(), Val: "SelectNotification"},
+				{Key: atom.Title.String(), Val: n.Printer.Text("Select")},
+			},
 		},
 	)
 	divClass := "list-entry-body multilist-entry mark-as-read"
 	if n.Read {
 		divClass += " read"
 	}
-	div := htmlg.DivClass(divClass, span1, span2)
+	div := htmlg.DivClass(divClass, sel, span1, snooze, mute, span2, span3)
+	div.Attr = append(div.Attr,
+		html.Attribute{Key: "data-repo-uri", Val: n.RepoSpec.URI},
+		html.Attribute{Key: "data-thread-type", Val: n.ThreadType},
+		html.Attribute{Key: "data-thread-id", Val: strconv.FormatUint(n.ThreadID, 10)},
+	)
 	switch {
 	case n.Mentioned:
 		div.Attr = append(div.Attr, html.Attribute{
@@ -258,19 +400,110 @@ func (n Notification) Render() []*html.Node {
 	return []*html.Node{div}
 }
 
+// actionForm returns a form that performs action op by submitting it along with fields
+// and CSRF token csrfToken via a POST request to the current page, so that it works without
+// the frontend script. The frontend performs the action in place instead, as specified by
+// the data-action attribute. The form is displayed as a button with the specified title and icon.
+func actionForm(op, csrfToken, title string, icon *html.Node, fields ...field) *html.Node {
+	form := &html.Node{
+		Type: html.ElementNode, Data: atom.Form.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Class.String(), Val: "action"},
+			{Key: atom.Method.String(), Val: "post"},
+			{Key: "data-action", Val: op},
+		},
+	}
+	for _, f := range append([]field{{csrf.FormField, csrfToken}, {"op", op}}, fields...) {
+		form.AppendChild(&html.Node{
+			Type: html.ElementNode, Data: atom.Input.String(),
+			Attr: []html.Attribute{
+				{Key: atom.Type.String(), Val: "hidden"},
+				{Key: atom.Name.String(), Val: f.Name},
+				{Key: atom.Value.String(), Val: f.Value},
+			},
+		})
+	}
+	form.AppendChild(&html.Node{
+		Type: html.ElementNode, Data: atom.Button.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Type.String(), Val: "submit"},
+			{Key: atom.Title.String(), Val: title},
+		},
+		FirstChild: icon,
+	})
+	return form
+}
+
+// field is a form field.
+type field struct {
+	Name  string
+	Value string
+}
+
+// SnoozeMenu component is a menu of choices of how long to snooze the thread of a notification for.
+// It's displayed within the Notification component, whose data-* attributes identify the thread.
+type SnoozeMenu struct {
+	Notification notifications.Notification
+	Printer      i18n.Printer // Printer for the language of the UI.
+}
+
+func (s SnoozeMenu) Render() []*html.Node {
+	// TODO: Make this much nicer.
+	/*
+		<details class="SnoozeMenu">
+			<summary title="Snooze"><octicon.Clock()></summary>
+			<div class="SnoozeMenu-choices">
+				{{range snooze.Choices}}
+					<a href="#" data-action="snooze" data-choice="{{.ID}}">{{.Label}}</a>
+				{{end}}
+			</div>
+		</details>
+	*/
+	choices := htmlg.DivClass("SnoozeMenu-choices")
+	for _, c := range snooze.Choices {
+		choices.AppendChild(&html.Node{
+			Type: html.ElementNode, Data: atom.A.String(),
+			Attr: []html.Attribute{
+				{Key: atom.Href.String(), Val: "#"},
+				{Key: "data-action", Val: "snooze"},
+				{Key: "data-choice", Val: c.ID},
+			},
+			FirstChild: htmlg.Text(s.Printer.Text(c.Label)),
+		})
+	}
+	summary := &html.Node{
+		Type: html.ElementNode, Data: atom.Summary.String(),
+		Attr:       []html.Attribute{{Key: atom.Title.String(), Val: s.Printer.Text("Snooze")}},
+		FirstChild: octicon.Clock(),
+	}
+	details := &html.Node{
+		Type: html.ElementNode, Data: atom.Details.String(),
+		Attr: []html.Attribute{{Key: atom.Class.String(), Val: "SnoozeMenu"}},
+	}
+	details.AppendChild(summary)
+	details.AppendChild(choices)
+	return []*html.Node{details}
+}
+
 // Time component that displays human friendly relative time (e.g., "2 hours ago", "yesterday"),
 // but also contains a tooltip with the full absolute time (e.g., "Jan 2, 2006, 3:04 PM MST").
+// Both are formatted for the language of Printer, and the absolute time is in its location.
+// The time is also included in machine-readable form, in the datetime attribute.
 type Time struct {
-	Time time.Time
+	Time    time.Time
+	Printer i18n.Printer
 }
 
 func (t Time) Render() []*html.Node {
 	// TODO: Make this much nicer.
-	// <abbr title="{{.Format "Jan 2, 2006, 3:04 PM MST"}}">{{reltime .}}</abbr>
-	abbr := &html.Node{
-		Type: html.ElementNode, Data: atom.Abbr.String(),
-		Attr:       []html.Attribute{{Key: atom.Title.String(), Val: t.Time.Format("Jan 2, 2006, 3:04 PM MST")}},
-		FirstChild: htmlg.Text(humanize.Time(t.Time)),
+	// <time datetime="{{.Time.Format time.RFC3339}}" title="{{.Printer.Time .Time}}">{{.Printer.RelTime .Time now}}</time>
+	el := &html.Node{
+		Type: html.ElementNode, Data: atom.Time.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Datetime.String(), Val: t.Time.Format(time.RFC3339)},
+			{Key: atom.Title.String(), Val: t.Printer.Time(t.Time)},
+		},
+		FirstChild: htmlg.Text(t.Printer.RelTime(t.Time, time.Now())),
 	}
-	return []*html.Node{abbr}
+	return []*html.Node{el}
 }
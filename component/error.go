@@ -0,0 +1,68 @@
+package component
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/shurcooL/htmlg"
+	"github.com/shurcooL/notificationsapp/i18n"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Error component displays an error that occurred while serving a page.
+type Error struct {
+	Code    int          // HTTP status code of the error.
+	Details string       // Details about the error, or empty if they shouldn't be shown to the user.
+	Printer i18n.Printer // Printer for the language of the UI.
+}
+
+func (e Error) Render() []*html.Node {
+	// TODO: Make this much nicer.
+	/*
+		<div class="Error list-entry-border">
+			<h2 class="Error-status">{{.Code}} {{StatusText .Code}}</h2>
+			{{with message .Code}}<p class="Error-message">{{.}}</p>{{end}}
+			{{with .Details}}<pre class="Error-details">{{.}}</pre>{{end}}
+		</div>
+	*/
+	div := htmlg.DivClass("Error list-entry-border",
+		&html.Node{
+			Type: html.ElementNode, Data: atom.H2.String(),
+			Attr:       []html.Attribute{{Key: atom.Class.String(), Val: "Error-status"}},
+			FirstChild: htmlg.Text(fmt.Sprintf("%d %s", e.Code, e.Printer.Text(http.StatusText(e.Code)))),
+		},
+	)
+	if message := e.message(); message != "" {
+		div.AppendChild(&html.Node{
+			Type: html.ElementNode, Data: atom.P.String(),
+			Attr:       []html.Attribute{{Key: atom.Class.String(), Val: "Error-message"}},
+			FirstChild: htmlg.Text(e.Printer.Text(message)),
+		})
+	}
+	if e.Details != "" {
+		div.AppendChild(&html.Node{
+			Type: html.ElementNode, Data: atom.Pre.String(),
+			Attr:       []html.Attribute{{Key: atom.Class.String(), Val: "Error-details"}},
+			FirstChild: htmlg.Text(e.Details),
+		})
+	}
+	return []*html.Node{div}
+}
+
+// message returns a message that explains the error to the user,
+// or empty string if there's nothing to add to its status.
+func (e Error) message() string {
+	switch e.Code {
+	case http.StatusBadRequest, http.StatusMethodNotAllowed:
+		return "The request couldn't be handled."
+	case http.StatusForbidden:
+		return "You don't have permission to view this page."
+	case http.StatusNotFound:
+		return "This page doesn't exist."
+	case http.StatusInternalServerError:
+		return "Something went wrong. Please try again later."
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,190 @@
+package component
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/shurcooL/htmlg"
+	"github.com/shurcooL/notificationsapp/filter"
+	"github.com/shurcooL/notificationsapp/i18n"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Filters component displays tabs for filtering notifications by read state,
+// reason, thread type, and a form for searching and filtering by actor.
+type Filters struct {
+	All     bool           // Whether read notifications are currently being shown.
+	Filter  filter.Options // Currently applied filter.
+	Printer i18n.Printer   // Printer for the language of the UI.
+}
+
+func (f Filters) Render() []*html.Node {
+	// TODO: Make this much nicer.
+	/*
+		<div class="Filters">
+			<span class="tab-group">{{tab "Unread"}} · {{tab "All"}}</span>
+			<span class="tab-group">{{tab "Any reason"}} · {{tab "Participating"}} · {{tab "Mentioned"}}</span>
+			<span class="tab-group">{{tab "Any type"}} · {{tab "Issues"}} · {{tab "Pull requests"}}</span>
+			<form class="tab-group" method="get">
+				{{range .OtherParameters}}<input type="hidden" name="{{.Name}}" value="{{.Value}}">{{end}}
+				<input type="search" name="q" placeholder="Search" value="{{.Filter.Query}}">
+				<input type="search" name="actor" placeholder="Actor" value="{{.Filter.Actor}}">
+			</form>
+		</div>
+	*/
+	readState := htmlg.SpanClass("tab-group", tabs(
+		tab{Text: f.Printer.Text("Unread"), Href: query(false, f.Filter), Selected: !f.All},
+		tab{Text: f.Printer.Text("All"), Href: query(true, f.Filter), Selected: f.All},
+	)...)
+
+	var (
+		anyReason     = f.Filter
+		participating = f.Filter
+		mentioned     = f.Filter
+	)
+	anyReason.Participating, anyReason.Mentioned = false, false
+	participating.Participating, participating.Mentioned = true, false
+	mentioned.Participating, mentioned.Mentioned = false, true
+	reason := htmlg.SpanClass("tab-group", tabs(
+		tab{Text: f.Printer.Text("Any reason"), Href: query(f.All, anyReason), Selected: anyReason == f.Filter},
+		tab{Text: f.Printer.Text("Participating"), Href: query(f.All, participating), Selected: participating == f.Filter},
+		tab{Text: f.Printer.Text("Mentioned"), Href: query(f.All, mentioned), Selected: mentioned == f.Filter},
+	)...)
+
+	threadTypes := []struct{ Text, ThreadType string }{
+		{f.Printer.Text("Any type"), ""},
+		{f.Printer.Text("Issues"), "Issue"},
+		{f.Printer.Text("Pull requests"), "PullRequest"},
+	}
+	if t := f.Filter.ThreadType; t != "" && t != "Issue" && t != "PullRequest" {
+		threadTypes = append(threadTypes, struct{ Text, ThreadType string }{t, t})
+	}
+	var ts []tab
+	for _, t := range threadTypes {
+		tf := f.Filter
+		tf.ThreadType = t.ThreadType
+		ts = append(ts, tab{Text: t.Text, Href: query(f.All, tf), Selected: t.ThreadType == f.Filter.ThreadType})
+	}
+	threadType := htmlg.SpanClass("tab-group", tabs(ts...)...)
+
+	form := &html.Node{
+		Type: html.ElementNode, Data: atom.Form.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Class.String(), Val: "tab-group"},
+			{Key: atom.Method.String(), Val: "get"},
+		},
+	}
+	other := f.Filter
+	other.Actor, other.Query = "", ""
+	hidden := values(f.All, other)
+	for _, name := range sortedKeys(hidden) {
+		form.AppendChild(&html.Node{
+			Type: html.ElementNode, Data: atom.Input.String(),
+			Attr: []html.Attribute{
+				{Key: atom.Type.String(), Val: "hidden"},
+				{Key: atom.Name.String(), Val: name},
+				{Key: atom.Value.String(), Val: hidden.Get(name)},
+			},
+		})
+	}
+	form.AppendChild(&html.Node{
+		Type: html.ElementNode, Data: atom.Input.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Type.String(), Val: "search"},
+			{Key: atom.Name.String(), Val: "q"},
+			{Key: atom.Placeholder.String(), Val: f.Printer.Text("Search")},
+			{Key: atom.Value.String(), Val: f.Filter.Query},
+		},
+	})
+	form.AppendChild(&html.Node{
+		Type: html.ElementNode, Data: atom.Input.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Type.String(), Val: "search"},
+			{Key: atom.Name.String(), Val: "actor"},
+			{Key: atom.Placeholder.String(), Val: f.Printer.Text("Actor")},
+			{Key: atom.Value.String(), Val: f.Filter.Actor},
+		},
+	})
+
+	div := htmlg.DivClass("Filters", readState, reason, threadType, form)
+	return []*html.Node{div}
+}
+
+// ParseQuery parses the query parameters q of a notifications page.
+// It's the inverse of the query strings generated by Filters.
+func ParseQuery(q url.Values) (all bool, f filter.Options) {
+	all, _ = strconv.ParseBool(q.Get("all"))
+	f.Participating, _ = strconv.ParseBool(q.Get("participating"))
+	f.Mentioned, _ = strconv.ParseBool(q.Get("mentioned"))
+	f.ThreadType = q.Get("type")
+	f.Actor = q.Get("actor")
+	f.Query = q.Get("q")
+	return all, f
+}
+
+// query returns the query string, including the leading '?', of a notifications
+// page that shows notifications matching filter f, including read ones if all is true.
+func query(all bool, f filter.Options) string {
+	return "?" + values(all, f).Encode()
+}
+
+// values returns the query parameters of a notifications page that shows
+// notifications matching filter f, including read ones if all is true.
+func values(all bool, f filter.Options) url.Values {
+	v := url.Values{}
+	if all {
+		v.Set("all", "1")
+	}
+	if f.Participating {
+		v.Set("participating", "1")
+	}
+	if f.Mentioned {
+		v.Set("mentioned", "1")
+	}
+	if f.ThreadType != "" {
+		v.Set("type", f.ThreadType)
+	}
+	if f.Actor != "" {
+		v.Set("actor", f.Actor)
+	}
+	if f.Query != "" {
+		v.Set("q", f.Query)
+	}
+	return v
+}
+
+func sortedKeys(v url.Values) []string {
+	var keys []string
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// tab is a single tab in a group of tabs.
+type tab struct {
+	Text     string
+	Href     string
+	Selected bool
+}
+
+// tabs renders ts separated by dots. The selected tab is rendered as strong text.
+func tabs(ts ...tab) []*html.Node {
+	var ns []*html.Node
+	for i, t := range ts {
+		if i != 0 {
+			ns = append(ns, htmlg.Text(" · "))
+		}
+		if t.Selected {
+			ns = append(ns, htmlg.Strong(t.Text))
+			continue
+		}
+		a := htmlg.A(t.Text, t.Href)
+		a.Attr = append(a.Attr, html.Attribute{Key: atom.Class.String(), Val: "black"})
+		ns = append(ns, a)
+	}
+	return ns
+}
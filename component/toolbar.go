@@ -0,0 +1,47 @@
+package component
+
+import (
+	"github.com/shurcooL/htmlg"
+	"github.com/shurcooL/notificationsapp/i18n"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Toolbar component displays controls for acting on selected notifications.
+// It's hidden until the frontend script makes it visible, since it needs it to work.
+type Toolbar struct {
+	Printer i18n.Printer // Printer for the language of the UI.
+}
+
+func (t Toolbar) Render() []*html.Node {
+	// TODO: Make this much nicer.
+	/*
+		<div class="Toolbar" hidden>
+			<label><input type="checkbox" data-action="select-all"> Select all</label>
+			<button type="button" data-action="mark-selected-read">Mark selected read</button>
+			<span class="Toolbar-status"></span>
+		</div>
+	*/
+	selectAll := &html.Node{
+		Type: html.ElementNode, Data: atom.Label.String(),
+	}
+	selectAll.AppendChild(&html.Node{
+		Type: html.ElementNode, Data: atom.Input.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Type.String(), Val: "checkbox"},
+			{Key: "data-action", Val: "select-all"},
+		},
+	})
+	selectAll.AppendChild(htmlg.Text(" " + t.Printer.Text("Select all")))
+	markRead := &html.Node{
+		Type: html.ElementNode, Data: atom.Button.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Type.String(), Val: "button"},
+			{Key: "data-action", Val: "mark-selected-read"},
+		},
+		FirstChild: htmlg.Text(t.Printer.Text("Mark selected read")),
+	}
+	div := htmlg.DivClass("Toolbar", selectAll, markRead, htmlg.SpanClass("Toolbar-status"))
+	div.Attr = append(div.Attr, html.Attribute{Key: atom.Hidden.String()})
+	return []*html.Node{div}
+}
@@ -0,0 +1,34 @@
+package component
+
+import (
+	"github.com/shurcooL/htmlg"
+	"github.com/shurcooL/notificationsapp/i18n"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// UndoBar component displays a transient message about an action, with a link to undo it.
+type UndoBar struct {
+	Text    string       // Message about the action. E.g., "Marked 3 notifications as read."
+	Printer i18n.Printer // Printer for the language of the UI.
+}
+
+func (u UndoBar) Render() []*html.Node {
+	// TODO: Make this much nicer.
+	/*
+		<div class="UndoBar">
+			<span class="UndoBar-text">{{.Text}}</span>
+			<a class="UndoBar-undo" href="#">Undo</a>
+		</div>
+	*/
+	undo := &html.Node{
+		Type: html.ElementNode, Data: atom.A.String(),
+		Attr: []html.Attribute{
+			{Key: atom.Class.String(), Val: "UndoBar-undo"},
+			{Key: atom.Href.String(), Val: "#"},
+		},
+		FirstChild: htmlg.Text(u.Printer.Text("Undo")),
+	}
+	div := htmlg.DivClass("UndoBar", htmlg.SpanClass("UndoBar-text", htmlg.Text(u.Text)), undo)
+	return []*html.Node{div}
+}
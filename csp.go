@@ -0,0 +1,26 @@
+package notificationsapp
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// DefaultContentSecurityPolicy is a strict Content-Security-Policy for use as
+// Options.ContentSecurityPolicy. It allows only the script and stylesheet of the page,
+// via nonce, and inline style attributes, which components use for per-notification styling.
+func DefaultContentSecurityPolicy(req *http.Request, nonce string) string {
+	return fmt.Sprintf("script-src 'nonce-%s'; style-src 'nonce-%s'; style-src-attr 'unsafe-inline'; "+
+		"object-src 'none'; base-uri 'none'", nonce, nonce)
+}
+
+// newNonce returns a new random nonce for use in a Content-Security-Policy.
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,74 @@
+// Package csrf contains protection of state-changing requests against cross-site
+// request forgery.
+//
+// It uses the double-submit cookie pattern. notificationsapp sets a random token
+// in a cookie, and includes it in pages it renders. A request is valid if it carries
+// the same token in a header or form field, which other sites can't read or set.
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Cookie is the name of the cookie that holds the token.
+	Cookie = "notificationsapp-csrf"
+
+	// Header is the name of the request header that carries the token.
+	Header = "X-CSRF-Token"
+
+	// FormField is the name of the form field that carries the token.
+	FormField = "csrf"
+)
+
+// Token returns the token for req. It's the token in the cookie of req if there is one,
+// otherwise a newly generated token, which is set in a cookie via w.
+func Token(w http.ResponseWriter, req *http.Request) (string, error) {
+	if cookie, err := req.Cookie(Cookie); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     Cookie,
+		Value:    token,
+		Path:     "/",
+		Secure:   req.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token, nil
+}
+
+// Valid reports whether req carries a valid token, either in the Header header
+// or in the FormField form field.
+func Valid(req *http.Request) bool {
+	cookie, err := req.Cookie(Cookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	token := req.Header.Get(Header)
+	if token == "" {
+		token = req.PostFormValue(FormField)
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) == 1
+}
+
+// TokenAuthenticated reports whether req is authenticated with a bearer access token
+// in the Authorization header, rather than with cookies. Other sites can't make
+// such requests on behalf of the user, so they don't need to carry a token.
+// Other schemes, such as Basic, don't count, since browsers attach cached
+// credentials for them to requests made by other sites.
+func TokenAuthenticated(req *http.Request) bool {
+	const prefix = "bearer "
+	auth := req.Header.Get("Authorization")
+	return len(auth) > len(prefix) && strings.HasPrefix(strings.ToLower(auth[:len(prefix)]), prefix)
+}
@@ -0,0 +1,85 @@
+package csrf_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/shurcooL/notificationsapp/csrf"
+)
+
+func TestValid(t *testing.T) {
+	rr := httptest.NewRecorder()
+	token, err := csrf.Token(rr, httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := rr.Result().Cookies()[0]
+
+	// The token is reused while the cookie is set.
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	if got, err := csrf.Token(rr, req); err != nil || got != token {
+		t.Errorf("got token %q, %v, want %q", got, err, token)
+	}
+	if len(rr.Result().Cookies()) != 0 {
+		t.Error("got a new cookie, want none")
+	}
+
+	tests := []struct {
+		name   string
+		cookie bool
+		header string
+		form   string
+		want   bool
+	}{
+		{"header", true, token, "", true},
+		{"form", true, "", token, true},
+		{"no token", true, "", "", false},
+		{"wrong token", true, "x" + token, "", false},
+		{"no cookie", false, token, "", false},
+	}
+	for _, tc := range tests {
+		v := url.Values{}
+		if tc.form != "" {
+			v.Set(csrf.FormField, tc.form)
+		}
+		req := httptest.NewRequest("POST", "/", strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if tc.cookie {
+			req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		}
+		if tc.header != "" {
+			req.Header.Set(csrf.Header, tc.header)
+		}
+		if got := csrf.Valid(req); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTokenAuthenticated(t *testing.T) {
+	tests := []struct {
+		auth string
+		want bool
+	}{
+		{"", false},
+		{"Bearer abc", true},
+		{"bearer abc", true},
+		{"Bearer ", false},
+		{"Basic dXNlcjpwYXNz", false},
+		{"Negotiate abc", false},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest("POST", "/", nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		if got := csrf.TokenAuthenticated(req); got != tc.want {
+			t.Errorf("%q: got %v, want %v", tc.auth, got, tc.want)
+		}
+	}
+}
@@ -2,83 +2,256 @@ package notificationsapp
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"runtime/debug"
+	"strings"
+	"time"
 
 	"github.com/shurcooL/httperror"
+	"github.com/shurcooL/notificationsapp/apierror"
 	"github.com/shurcooL/users"
 )
 
+// APIHandler returns an http.Handler for the httphandler.Notifications method h,
+// which handles errors it returns the same way the app does. If opt.Logger is not nil,
+// each request is logged to it. Failed requests are responded to with an apierror.Error
+// JSON error envelope. Mount uses it to register HTTP API endpoints.
+// For example:
+//
+// 	http.Handle(httproute.List, notificationsapp.APIHandler(apiHandler.List, users, opt))
+func APIHandler(h func(w http.ResponseWriter, req *http.Request) error, users users.Service, opt Options) http.Handler {
+	return &errorHandler{handler: h, users: users, logger: opt.Logger, onError: opt.OnError, json: true}
+}
+
 // errorHandler factors error handling out of the HTTP handler.
+// It recovers panics in the handler, and handles them as errors.
 type errorHandler struct {
 	handler func(w http.ResponseWriter, req *http.Request) error
 	users   interface {
 		GetAuthenticated(context.Context) (users.User, error)
 	} // May be nil if there's no users service.
+	logger Logger // May be nil, in which case only errors are logged, via package log.
+
+	// onError, if not nil, is called with failures. See Options.OnError.
+	onError func(req *http.Request, err error, stack []byte)
+
+	// errorPage, if not nil, renders an HTML page for error with status code code
+	// to w. details, if not empty, describes the error further. It's used for
+	// requests that accept HTML, others are responded to with JSON or plain text.
+	errorPage func(w http.ResponseWriter, req *http.Request, code int, details string) error
+
+	// json is whether to respond with JSON errors regardless of
+	// the Accept header, as HTTP API endpoints do.
+	json bool
 }
 
 func (h *errorHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	start := time.Now()
 	rw := &responseWriter{ResponseWriter: w}
-	err := h.handler(rw, req)
+	err := recoverPanic(func() error { return h.handler(rw, req) })
+	class := h.handleError(rw, req, err)
+	code := rw.StatusCode()
+	h.log(req, code, time.Since(start), class, err)
+	// Handlers that write a 5xx response themselves and return nil aren't failures
+	// that OnError can report, since there's no error.
+	if h.onError != nil && err != nil && (code >= 500 || class == "after_header") {
+		h.onError(req, err, panicStack(err))
+	}
+}
+
+// recoverPanic calls f, and returns the error it returns.
+// If f panics, the panic is recovered and returned as a panicError,
+// unless it's http.ErrAbortHandler, which is meant to abort the response.
+func recoverPanic(f func() error) (err error) {
+	defer func() {
+		e := recover()
+		if e == nil {
+			return
+		} else if e == http.ErrAbortHandler {
+			panic(e)
+		}
+		err = panicError{value: e, stack: debug.Stack()}
+	}()
+	return f()
+}
+
+// panicError is an error that represents a recovered panic.
+type panicError struct {
+	value interface{} // Value passed to panic.
+	stack []byte      // Stack trace of the goroutine that panicked.
+}
+
+func (e panicError) Error() string { return fmt.Sprintf("panic: %v", e.value) }
+
+// panicStack returns the stack trace of err if it's or wraps a panicError,
+// or nil otherwise.
+func panicStack(err error) []byte {
+	var e panicError
+	if errors.As(err, &e) {
+		return e.stack
+	}
+	return nil
+}
+
+// handleError responds to req with error err, if it's not nil,
+// and returns its error class (see Logger). An empty class means err
+// is nil or isn't a failure (e.g., httperror.Redirect).
+func (h *errorHandler) handleError(w *responseWriter, req *http.Request, err error) (class string) {
 	if err == nil {
 		// Do nothing.
-		return
+		return ""
 	}
-	if err != nil && rw.WroteHeader {
+	if err != nil && w.WroteHeader {
 		// The header has already been written, so it's too late to send
 		// a different status code. Just log the error and move on.
-		log.Println(err)
-		return
+		return "after_header"
+	}
+	if err, ok := httperror.IsJSONResponse(err); ok {
+		w.Header().Set("Content-Type", "application/json")
+		jw := json.NewEncoder(w)
+		jw.SetIndent("", "\t")
+		err := jw.Encode(err.V)
+		if err != nil {
+			h.logError(req, fmt.Errorf("error encoding JSONResponse: %v", err))
+		}
+		return ""
 	}
 	if err, ok := httperror.IsMethod(err); ok {
-		httperror.HandleMethod(w, err)
-		return
+		w.Header().Set("Allow", strings.Join(err.Allowed, ", "))
+		h.writeError(w, req, apierror.Error{Code: http.StatusMethodNotAllowed, Message: err.Error()})
+		return "method"
 	}
 	if err, ok := httperror.IsRedirect(err); ok {
 		http.Redirect(w, req, err.URL, http.StatusSeeOther)
-		return
+		return ""
 	}
 	if err, ok := httperror.IsBadRequest(err); ok {
-		httperror.HandleBadRequest(w, err)
-		return
+		e := apierror.Error{Code: http.StatusBadRequest, Message: err.Error()}
+		var fe apierror.FieldError
+		if errors.As(err.Err, &fe) {
+			e.Field = fe.Field
+		}
+		h.writeError(w, req, e)
+		return "bad_request"
 	}
 	if err, ok := httperror.IsHTTP(err); ok {
-		code := err.Code
-		error := fmt.Sprintf("%d %s", code, http.StatusText(code))
-		if user, e := h.getAuthenticated(req.Context()); e == nil && user.SiteAdmin {
-			error += "\n\n" + err.Error()
+		h.writeError(w, req, apierror.Error{Code: err.Code, Message: h.adminDetails(req, err)})
+		return "http"
+	}
+	if os.IsNotExist(err) || errors.Is(err, os.ErrNotExist) {
+		h.writeError(w, req, apierror.Error{Code: http.StatusNotFound, Message: h.adminDetails(req, err)})
+		return "not_exist"
+	}
+	if os.IsPermission(err) || errors.Is(err, os.ErrPermission) {
+		h.writeError(w, req, apierror.Error{Code: http.StatusForbidden, Message: h.adminDetails(req, err)})
+		return "permission"
+	}
+
+	h.writeError(w, req, apierror.Error{Code: http.StatusInternalServerError, Message: h.adminDetails(req, err)})
+	return "internal"
+}
+
+// adminDetails returns details of err if the user making req is an admin,
+// or empty string otherwise.
+func (h *errorHandler) adminDetails(req *http.Request, err error) string {
+	if user, e := h.getAuthenticated(req.Context()); e == nil && user.SiteAdmin {
+		return err.Error()
+	}
+	return ""
+}
+
+// writeError responds to req with error e. e.Message, if not empty,
+// describes the error further than its status code.
+// The response is an apierror.Error JSON error envelope if h.json is set
+// or req prefers JSON, an HTML error page if there's errorPage and req
+// accepts HTML, and plain text otherwise.
+func (h *errorHandler) writeError(w *responseWriter, req *http.Request, e apierror.Error) {
+	code, details := e.Code, e.Message
+	if h.json || acceptsJSON(req) {
+		if e.Message == "" {
+			e.Message = http.StatusText(code)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(code)
+		err := json.NewEncoder(w).Encode(e)
+		if err != nil {
+			h.logError(req, fmt.Errorf("error encoding error: %v", err))
 		}
-		http.Error(w, error, code)
 		return
 	}
-	if os.IsNotExist(err) {
-		log.Println(err)
-		error := "404 Not Found"
-		if user, e := h.getAuthenticated(req.Context()); e == nil && user.SiteAdmin {
-			error += "\n\n" + err.Error()
+	if h.errorPage != nil && acceptsHTML(req) {
+		err := recoverPanic(func() error { return h.errorPage(w, req, code, details) })
+		if err == nil {
+			return
 		}
-		http.Error(w, error, http.StatusNotFound)
-		return
+		h.logError(req, fmt.Errorf("error rendering error page: %w", err))
+		if w.WroteHeader {
+			return
+		}
+		// Fall back to plain text.
 	}
-	if os.IsPermission(err) {
-		log.Println(err)
-		error := "403 Forbidden"
-		if user, e := h.getAuthenticated(req.Context()); e == nil && user.SiteAdmin {
-			error += "\n\n" + err.Error()
+	error := fmt.Sprintf("%d %s", code, http.StatusText(code))
+	if details != "" {
+		error += "\n\n" + details
+	}
+	http.Error(w, error, code)
+}
+
+// log logs req, which was responded to with status code after latency.
+// If the handler returned an error, class is its error class and err is the error.
+// Without a logger, only errors of classes that indicate a failure
+// on the server side, or in accessing resources, are logged.
+func (h *errorHandler) log(req *http.Request, code int, latency time.Duration, class string, err error) {
+	if h.logger == nil {
+		switch class {
+		case "after_header", "not_exist", "permission", "internal":
+			if stack := panicStack(err); stack != nil {
+				log.Printf("%v\n%s", err, stack)
+				return
+			}
+			log.Println(err)
 		}
-		http.Error(w, error, http.StatusForbidden)
 		return
 	}
+	args := []interface{}{
+		"method", req.Method,
+		"path", req.URL.Path,
+		"status", code,
+		"latency", latency,
+	}
+	if user, e := h.getAuthenticated(req.Context()); e == nil && user.ID != 0 {
+		args = append(args, "user", fmt.Sprintf("%d@%s", user.ID, user.Domain))
+	}
+	if class != "" {
+		args = append(args, "error_class", class, "error", err.Error())
+	}
+	if stack := panicStack(err); stack != nil {
+		args = append(args, "stack", string(stack))
+	}
+	if code >= 500 || class == "after_header" {
+		h.logger.ErrorContext(req.Context(), "request", args...)
+		return
+	}
+	h.logger.InfoContext(req.Context(), "request", args...)
+}
 
-	log.Println(err)
-	error := "500 Internal Server Error"
-	if user, e := h.getAuthenticated(req.Context()); e == nil && user.SiteAdmin {
-		error += "\n\n" + err.Error()
+// logError logs error err that occurred while handling req,
+// and reports it to onError.
+func (h *errorHandler) logError(req *http.Request, err error) {
+	if h.onError != nil {
+		h.onError(req, err, panicStack(err))
 	}
-	http.Error(w, error, http.StatusInternalServerError)
+	if h.logger == nil {
+		log.Println(err)
+		return
+	}
+	h.logger.ErrorContext(req.Context(), err.Error(), "method", req.Method, "path", req.URL.Path)
 }
 
 func (h *errorHandler) getAuthenticated(ctx context.Context) (users.User, error) {
@@ -89,11 +262,12 @@ func (h *errorHandler) getAuthenticated(ctx context.Context) (users.User, error)
 }
 
 // responseWriter wraps a real http.ResponseWriter and captures
-// whether or not the header has been written.
+// whether or not the header has been written, and its status code.
 type responseWriter struct {
 	http.ResponseWriter
 
 	WroteHeader bool // Write or WriteHeader was called.
+	code        int  // Status code passed to WriteHeader, or 0 if it wasn't called.
 }
 
 func (rw *responseWriter) Write(p []byte) (n int, err error) {
@@ -101,6 +275,22 @@ func (rw *responseWriter) Write(p []byte) (n int, err error) {
 	return rw.ResponseWriter.Write(p)
 }
 func (rw *responseWriter) WriteHeader(code int) {
+	if !rw.WroteHeader {
+		rw.code = code
+	}
 	rw.WroteHeader = true
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// StatusCode returns the status code of the response.
+// It's 200 OK if WriteHeader wasn't called explicitly.
+func (rw *responseWriter) StatusCode() int {
+	if rw.code == 0 {
+		return http.StatusOK
+	}
+	return rw.code
+}
+
+// Unwrap returns the underlying http.ResponseWriter, so that optional
+// interfaces it implements, like http.Flusher, can be found.
+func (rw *responseWriter) Unwrap() http.ResponseWriter { return rw.ResponseWriter }
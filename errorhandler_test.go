@@ -0,0 +1,178 @@
+package notificationsapp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/shurcooL/httperror"
+)
+
+func TestErrorHandlerLog(t *testing.T) {
+	tests := []struct {
+		err       error
+		wantLevel string
+		wantCode  int
+		wantClass interface{}
+	}{
+		{nil, "info", http.StatusOK, nil},
+		{httperror.Redirect{URL: "/"}, "info", http.StatusSeeOther, nil},
+		{httperror.BadRequest{Err: fmt.Errorf("bad")}, "info", http.StatusBadRequest, "bad_request"},
+		{os.ErrNotExist, "info", http.StatusNotFound, "not_exist"},
+		{fmt.Errorf("boom"), "error", http.StatusInternalServerError, "internal"},
+	}
+	for _, tc := range tests {
+		var l recordLogger
+		h := &errorHandler{
+			handler: func(w http.ResponseWriter, req *http.Request) error { return tc.err },
+			logger:  &l,
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", "/api/notifications/mark-read", nil))
+		if len(l) != 1 {
+			t.Fatalf("%v: got %d records, want 1", tc.err, len(l))
+		}
+		r := l[0]
+		if r.level != tc.wantLevel || r.msg != "request" {
+			t.Errorf("%v: got %s %q, want %s %q", tc.err, r.level, r.msg, tc.wantLevel, "request")
+		}
+		if got := r.attrs["status"]; got != tc.wantCode || rr.Code != tc.wantCode {
+			t.Errorf("%v: got status %v (response %d), want %d", tc.err, got, rr.Code, tc.wantCode)
+		}
+		if got := r.attrs["error_class"]; got != tc.wantClass {
+			t.Errorf("%v: got error_class %v, want %v", tc.err, got, tc.wantClass)
+		}
+		if r.attrs["method"] != "POST" || r.attrs["path"] != "/api/notifications/mark-read" {
+			t.Errorf("%v: got method %v path %v", tc.err, r.attrs["method"], r.attrs["path"])
+		}
+	}
+}
+
+// recordLogger is a Logger that records logged records.
+type recordLogger []record
+
+type record struct {
+	level string
+	msg   string
+	attrs map[string]interface{}
+}
+
+func (l *recordLogger) InfoContext(_ context.Context, msg string, args ...interface{}) {
+	l.log("info", msg, args)
+}
+func (l *recordLogger) ErrorContext(_ context.Context, msg string, args ...interface{}) {
+	l.log("error", msg, args)
+}
+func (l *recordLogger) log(level, msg string, args []interface{}) {
+	attrs := make(map[string]interface{})
+	for i := 0; i+1 < len(args); i += 2 {
+		attrs[args[i].(string)] = args[i+1]
+	}
+	*l = append(*l, record{level: level, msg: msg, attrs: attrs})
+}
+
+func TestErrorHandlerFormat(t *testing.T) {
+	h := &errorHandler{
+		handler: func(w http.ResponseWriter, req *http.Request) error { return os.ErrNotExist },
+		errorPage: func(w http.ResponseWriter, req *http.Request, code int, details string) error {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(code)
+			_, err := fmt.Fprintf(w, "<p>%d</p>", code)
+			return err
+		},
+	}
+	tests := []struct {
+		accept          string
+		wantContentType string
+		wantBody        string
+	}{
+		{"", "text/plain; charset=utf-8", "404 Not Found\n"},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", "text/html; charset=utf-8", "<p>404</p>"},
+		{"application/json", "application/json", `{"code":404,"message":"Not Found"}` + "\n"},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", tc.accept)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%q: got status %d, want %d", tc.accept, rr.Code, http.StatusNotFound)
+		}
+		if got := rr.Header().Get("Content-Type"); got != tc.wantContentType {
+			t.Errorf("%q: got Content-Type %q, want %q", tc.accept, got, tc.wantContentType)
+		}
+		if got := rr.Body.String(); got != tc.wantBody {
+			t.Errorf("%q: got body %q, want %q", tc.accept, got, tc.wantBody)
+		}
+	}
+}
+
+func TestErrorHandlerPanic(t *testing.T) {
+	var (
+		gotErr   error
+		gotStack []byte
+	)
+	h := &errorHandler{
+		handler: func(w http.ResponseWriter, req *http.Request) error { panic("boom") },
+		onError: func(req *http.Request, err error, stack []byte) { gotErr, gotStack = err, stack },
+		logger:  new(recordLogger),
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusInternalServerError)
+	}
+	if gotErr == nil || gotErr.Error() != "panic: boom" {
+		t.Errorf("got error %v, want %q", gotErr, "panic: boom")
+	}
+	if len(gotStack) == 0 {
+		t.Error("got no stack, want one")
+	}
+}
+
+func TestErrorHandlerOnError(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(w http.ResponseWriter, req *http.Request) error
+		want    bool // Whether OnError is called.
+	}{
+		{
+			name:    "error",
+			handler: func(w http.ResponseWriter, req *http.Request) error { return fmt.Errorf("boom") },
+			want:    true,
+		},
+		{
+			name: "5xx written by handler",
+			handler: func(w http.ResponseWriter, req *http.Request) error {
+				http.Error(w, "503 Service Unavailable", http.StatusServiceUnavailable)
+				return nil
+			},
+			want: false,
+		},
+		{
+			name:    "client error",
+			handler: func(w http.ResponseWriter, req *http.Request) error { return os.ErrNotExist },
+			want:    false,
+		},
+	}
+	for _, tc := range tests {
+		var calls []error
+		h := &errorHandler{
+			handler: tc.handler,
+			onError: func(req *http.Request, err error, stack []byte) { calls = append(calls, err) },
+			logger:  new(recordLogger),
+		}
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if got := len(calls) == 1; got != tc.want || len(calls) > 1 {
+			t.Errorf("%s: got OnError calls %v, want called %v", tc.name, calls, tc.want)
+		}
+		for _, err := range calls {
+			if err == nil {
+				t.Errorf("%s: OnError called with nil error", tc.name)
+			}
+		}
+	}
+}
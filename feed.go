@@ -0,0 +1,204 @@
+package notificationsapp
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/shurcooL/httperror"
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/component"
+	"github.com/shurcooL/notificationsapp/validator"
+)
+
+// FeedHandler serves the notifications that NotificationsHandler displays
+// as a feed in the specified format, either "atom" or "json".
+func (h *handler) FeedHandler(w http.ResponseWriter, req *http.Request, format string) error {
+	if req.Method != "GET" {
+		return httperror.Method{Allowed: []string{"GET"}}
+	}
+
+	all, f := component.ParseQuery(req.URL.Query())
+	page, err := h.listPage(req, notifications.ListOptions{
+		All: all,
+	}, f)
+	if err != nil {
+		return err
+	}
+	ns := page.Notifications
+
+	// Feeds list unread notifications unless all=1 is given, so marking one
+	// as read can remove its entry without changing the newest UpdatedAt time.
+	// That's why the newest UpdatedAt time is used only as the feed's updated time,
+	// and not as its Last-Modified time, which NotModified determines.
+	if validator.NotModified(w, req, validator.ETag("feed."+format, ns)) {
+		return nil
+	}
+	lastModified := validator.LastModified(ns)
+
+	feedURL := requestURL(req)
+	homeURL := feedURL.ResolveReference(&url.URL{Path: req.Context().Value(BaseURIContextKey).(string) + "/"})
+	switch format {
+	case "atom":
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		return writeAtomFeed(w, ns, feedURL.String(), homeURL.String(), lastModified)
+	case "json":
+		w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+		return writeJSONFeed(w, ns, feedURL.String(), homeURL.String())
+	default:
+		return fmt.Errorf("unsupported feed format %q", format)
+	}
+}
+
+// requestURL returns the absolute URL of req, as it was originally requested by the client.
+func requestURL(req *http.Request) *url.URL {
+	u, err := url.ParseRequestURI(req.RequestURI)
+	if err != nil || req.RequestURI == "" {
+		u = &url.URL{Path: req.URL.Path, RawQuery: req.URL.RawQuery}
+	}
+	u.Scheme = "http"
+	if req.TLS != nil {
+		u.Scheme = "https"
+	}
+	u.Host = req.Host
+	return u
+}
+
+// threadURL returns the address of the thread that notification n is about.
+// It's the notification target address without the fragment, which is
+// typically used to point to a specific comment in the thread.
+func threadURL(n notifications.Notification) string {
+	u, err := url.Parse(n.HTMLURL)
+	if err != nil {
+		return n.HTMLURL
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// writeAtomFeed writes notifications ns to w as an Atom feed, as specified in RFC 4287.
+// updated is the time the feed was last updated. If it's zero, e.g., because
+// the feed is empty, the current time is used.
+func writeAtomFeed(w io.Writer, ns notifications.Notifications, feedURL, homeURL string, updated time.Time) error {
+	type link struct {
+		Rel  string `xml:"rel,attr,omitempty"`
+		Type string `xml:"type,attr,omitempty"`
+		Href string `xml:"href,attr"`
+	}
+	type person struct {
+		Name string `xml:"name"`
+		URI  string `xml:"uri,omitempty"`
+	}
+	type category struct {
+		Term string `xml:"term,attr"`
+	}
+	type entry struct {
+		Title    string   `xml:"title"`
+		ID       string   `xml:"id"`
+		Link     link     `xml:"link"`
+		Updated  string   `xml:"updated"`
+		Author   *person  `xml:"author"`
+		Category category `xml:"category"`
+	}
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	feed := struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		Title   string   `xml:"title"`
+		ID      string   `xml:"id"`
+		Updated string   `xml:"updated"`
+		Author  person   `xml:"author"` // Author of entries that don't have one.
+		Links   []link   `xml:"link"`
+		Entries []entry  `xml:"entry"`
+	}{
+		Title:   "Notifications",
+		ID:      feedURL,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Author:  person{Name: "Notifications", URI: homeURL},
+		Links: []link{
+			{Rel: "self", Type: "application/atom+xml", Href: feedURL},
+			{Rel: "alternate", Type: "text/html", Href: homeURL},
+		},
+	}
+	for _, n := range ns {
+		e := entry{
+			Title:    n.Title,
+			ID:       threadURL(n),
+			Link:     link{Rel: "alternate", Type: "text/html", Href: n.HTMLURL},
+			Updated:  n.UpdatedAt.UTC().Format(time.RFC3339),
+			Category: category{Term: n.RepoSpec.URI},
+		}
+		if n.Actor.Login != "" {
+			e.Author = &person{Name: n.Actor.Login, URI: n.Actor.HTMLURL}
+		}
+		feed.Entries = append(feed.Entries, e)
+	}
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return fmt.Errorf("io.WriteString: %v", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	err = enc.Encode(feed)
+	if err != nil {
+		return fmt.Errorf("xml.Encoder.Encode: %v", err)
+	}
+	return nil
+}
+
+// writeJSONFeed writes notifications ns to w as a JSON Feed, as specified at https://jsonfeed.org/version/1.1.
+func writeJSONFeed(w io.Writer, ns notifications.Notifications, feedURL, homeURL string) error {
+	type author struct {
+		Name   string `json:"name"`
+		URL    string `json:"url,omitempty"`
+		Avatar string `json:"avatar,omitempty"`
+	}
+	type item struct {
+		ID           string   `json:"id"`
+		URL          string   `json:"url"`
+		Title        string   `json:"title"`
+		ContentText  string   `json:"content_text"`
+		DateModified string   `json:"date_modified"`
+		Authors      []author `json:"authors,omitempty"`
+		Tags         []string `json:"tags"`
+	}
+	feed := struct {
+		Version     string `json:"version"`
+		Title       string `json:"title"`
+		HomePageURL string `json:"home_page_url"`
+		FeedURL     string `json:"feed_url"`
+		Items       []item `json:"items"`
+	}{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       "Notifications",
+		HomePageURL: homeURL,
+		FeedURL:     feedURL,
+		Items:       []item{},
+	}
+	for _, n := range ns {
+		i := item{
+			ID:           threadURL(n),
+			URL:          n.HTMLURL,
+			Title:        n.Title,
+			ContentText:  n.Title,
+			DateModified: n.UpdatedAt.UTC().Format(time.RFC3339),
+			Tags:         []string{n.RepoSpec.URI},
+		}
+		if n.Actor.Login != "" {
+			i.Authors = []author{{Name: n.Actor.Login, URL: n.Actor.HTMLURL, Avatar: n.Actor.AvatarURL}}
+		}
+		feed.Items = append(feed.Items, i)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	err := enc.Encode(feed)
+	if err != nil {
+		return fmt.Errorf("json.Encoder.Encode: %v", err)
+	}
+	return nil
+}
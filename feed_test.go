@@ -0,0 +1,85 @@
+package notificationsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shurcooL/notifications"
+)
+
+func TestFeedHandlerIfModifiedSince(t *testing.T) {
+	updated := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	ns := notifications.Notifications{
+		{RepoSpec: notifications.RepoSpec{URI: "example.org/repo"}, ThreadType: "Issue", ThreadID: 1, UpdatedAt: updated.Add(-time.Hour)},
+		{RepoSpec: notifications.RepoSpec{URI: "example.org/repo"}, ThreadType: "Issue", ThreadID: 2, UpdatedAt: updated},
+	}
+	for _, format := range []string{"atom", "json"} {
+		h := &handler{ns: listService{ns: ns}}
+		get := func(ifModifiedSince string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/feed."+format, nil)
+			req = req.WithContext(context.WithValue(req.Context(), BaseURIContextKey, ""))
+			if ifModifiedSince != "" {
+				req.Header.Set("If-Modified-Since", ifModifiedSince)
+			}
+			rr := httptest.NewRecorder()
+			err := h.FeedHandler(rr, req, format)
+			if err != nil {
+				t.Fatalf("%s: %v", format, err)
+			}
+			return rr
+		}
+
+		lastModified := get("").Header().Get("Last-Modified")
+		if lastModified == "" {
+			t.Fatalf("%s: got no Last-Modified header", format)
+		}
+		if rr := get(lastModified); rr.Code != http.StatusNotModified {
+			t.Errorf("%s: got status %v for unchanged feed, want %v", format, rr.Code, http.StatusNotModified)
+		}
+
+		// Marking the older notification as read removes it from the feed
+		// without changing the newest UpdatedAt time.
+		h.ns = listService{ns: ns[1:]}
+		if rr := get(lastModified); rr.Code != http.StatusOK {
+			t.Errorf("%s: got status %v after marking a notification as read, want %v", format, rr.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestWriteAtomFeedEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeAtomFeed(&buf, nil, "https://example.org/feed.atom", "https://example.org/", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var feed struct {
+		Updated time.Time `xml:"updated"`
+		Author  struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+	}
+	err = xml.Unmarshal(buf.Bytes(), &feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Updated.Before(time.Now().Add(-time.Minute)) {
+		t.Errorf("got updated %v, want current time", feed.Updated)
+	}
+	if feed.Author.Name == "" {
+		t.Error("got no feed author, want one")
+	}
+}
+
+type listService struct {
+	notifications.Service
+	ns notifications.Notifications
+}
+
+func (s listService) List(context.Context, notifications.ListOptions) (notifications.Notifications, error) {
+	return s.ns, nil
+}
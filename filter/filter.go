@@ -0,0 +1,133 @@
+// Package filter contains notification filtering.
+package filter
+
+import (
+	"context"
+	"strings"
+
+	"github.com/shurcooL/notifications"
+)
+
+// Options for filtering notifications.
+// The zero value matches all notifications.
+type Options struct {
+	// Participating, if true, matches only notifications in threads the user
+	// is participating in. Notifications where the user was @mentioned count
+	// as participating.
+	Participating bool
+
+	// Mentioned, if true, matches only notifications where the user was @mentioned.
+	Mentioned bool
+
+	// ThreadType, if not empty, matches only notifications of that thread type.
+	// E.g., "Issue" or "PullRequest".
+	ThreadType string
+
+	// Actor, if not empty, matches only notifications whose actor has that login.
+	Actor string
+
+	// Query, if not empty, is a search query. It matches only notifications where
+	// each of its words occurs in the title, repository URI or actor login.
+	// Matching is case-insensitive.
+	Query string
+}
+
+// IsZero reports whether o is the zero value, which matches all notifications.
+func (o Options) IsZero() bool {
+	return o == Options{}
+}
+
+// Match reports whether notification n matches o.
+func (o Options) Match(n notifications.Notification) bool {
+	if o.Participating && !n.Participating && !n.Mentioned {
+		return false
+	}
+	if o.Mentioned && !n.Mentioned {
+		return false
+	}
+	if o.ThreadType != "" && n.ThreadType != o.ThreadType {
+		return false
+	}
+	if o.Actor != "" && n.Actor.Login != o.Actor {
+		return false
+	}
+	if o.Query != "" && !matchQuery(n, o.Query) {
+		return false
+	}
+	return true
+}
+
+// matchQuery reports whether each word in query occurs in the
+// title, repository URI or actor login of notification n.
+func matchQuery(n notifications.Notification, query string) bool {
+	var (
+		title   = strings.ToLower(n.Title)
+		repoURI = strings.ToLower(n.RepoSpec.URI)
+		login   = strings.ToLower(n.Actor.Login)
+	)
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(title, word) &&
+			!strings.Contains(repoURI, word) &&
+			!strings.Contains(login, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns notifications in ns that match o.
+// It doesn't modify ns, which may be shared by the service that returned it.
+func (o Options) Apply(ns notifications.Notifications) notifications.Notifications {
+	if o.IsZero() {
+		return ns
+	}
+	filtered := make(notifications.Notifications, 0, len(ns))
+	for _, n := range ns {
+		if !o.Match(n) {
+			continue
+		}
+		filtered = append(filtered, n)
+	}
+	return filtered
+}
+
+// Lister is an optional interface that a notifications.Service
+// can implement to perform filtering natively.
+type Lister interface {
+	// ListFiltered lists notifications for authenticated user that match f.
+	// Returns a permission error if no authenticated user.
+	ListFiltered(ctx context.Context, opt notifications.ListOptions, f Options) (notifications.Notifications, error)
+}
+
+// Searcher is an optional interface that a notifications.Service
+// can implement to perform search natively, e.g., by using an index.
+type Searcher interface {
+	// Search lists notifications for authenticated user that match query.
+	// See Options.Query for the semantics of query.
+	// Returns a permission error if no authenticated user.
+	Search(ctx context.Context, opt notifications.ListOptions, query string) (notifications.Notifications, error)
+}
+
+// List lists notifications in service that match f.
+// If service implements Lister, it's used to perform filtering natively.
+// Otherwise, if f has a query and service implements Searcher, it's used to
+// perform search natively, and the rest of filtering is done in memory.
+// Otherwise, notifications returned by service.List are filtered in memory.
+func List(ctx context.Context, service notifications.Service, opt notifications.ListOptions, f Options) (notifications.Notifications, error) {
+	if l, ok := service.(Lister); ok {
+		return l.ListFiltered(ctx, opt, f)
+	}
+	if s, ok := service.(Searcher); ok && f.Query != "" {
+		ns, err := s.Search(ctx, opt, f.Query)
+		if err != nil {
+			return nil, err
+		}
+		f.Query = ""
+		return f.Apply(ns), nil
+	}
+	ns, err := service.List(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	return f.Apply(ns), nil
+}
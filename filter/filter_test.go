@@ -0,0 +1,56 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/filter"
+	"github.com/shurcooL/users"
+)
+
+func TestOptionsMatch(t *testing.T) {
+	var (
+		watching      = notifications.Notification{RepoSpec: notifications.RepoSpec{URI: "github.com/nsf/gocode"}, ThreadType: "Issue", Title: "package_bin: support type alias", Actor: users.User{Login: "gopher"}}
+		participating = notifications.Notification{ThreadType: "PullRequest", Participating: true}
+		mentioned     = notifications.Notification{ThreadType: "Issue", Mentioned: true}
+	)
+	tests := []struct {
+		f    filter.Options
+		n    notifications.Notification
+		want bool
+	}{
+		{filter.Options{}, watching, true},
+		{filter.Options{Participating: true}, watching, false},
+		{filter.Options{Participating: true}, participating, true},
+		{filter.Options{Participating: true}, mentioned, true},
+		{filter.Options{Mentioned: true}, participating, false},
+		{filter.Options{Mentioned: true}, mentioned, true},
+		{filter.Options{ThreadType: "Issue"}, watching, true},
+		{filter.Options{ThreadType: "Issue"}, participating, false},
+		{filter.Options{Actor: "gopher"}, watching, true},
+		{filter.Options{Actor: "gopher"}, mentioned, false},
+		{filter.Options{Query: "Type Alias"}, watching, true},
+		{filter.Options{Query: "gocode alias gopher"}, watching, true},
+		{filter.Options{Query: "alias gopherjs"}, watching, false},
+		{filter.Options{Query: "alias"}, mentioned, false},
+	}
+	for i, tc := range tests {
+		if got := tc.f.Match(tc.n); got != tc.want {
+			t.Errorf("%d: %+v.Match: got %v, want %v", i, tc.f, got, tc.want)
+		}
+	}
+}
+
+func TestOptionsApply(t *testing.T) {
+	ns := notifications.Notifications{
+		{ThreadID: 1, Actor: users.User{Login: "alice"}},
+		{ThreadID: 2, Actor: users.User{Login: "bob"}},
+	}
+	got := filter.Options{Actor: "bob"}.Apply(ns)
+	if len(got) != 1 || got[0].ThreadID != 2 {
+		t.Errorf("got %+v, want only thread 2", got)
+	}
+	if ns[0].ThreadID != 1 || ns[1].ThreadID != 2 {
+		t.Errorf("Apply modified its input: %+v", ns)
+	}
+}
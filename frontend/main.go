@@ -6,66 +6,266 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gopherjs/gopherjs/js"
-	"github.com/shurcooL/go/gopherjs_http/jsutil"
+	"github.com/shurcooL/htmlg"
 	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/batch"
+	"github.com/shurcooL/notificationsapp/component"
+	"github.com/shurcooL/notificationsapp/csrf"
 	"github.com/shurcooL/notificationsapp/httpclient"
+	"github.com/shurcooL/notificationsapp/httproute"
+	"github.com/shurcooL/notificationsapp/i18n"
+	"github.com/shurcooL/notificationsapp/mute"
+	"github.com/shurcooL/notificationsapp/pagination"
+	"github.com/shurcooL/notificationsapp/snooze"
+	"github.com/shurcooL/notificationsapp/stream"
+	"github.com/shurcooL/notificationsapp/undo"
+	"github.com/shurcooL/notificationsapp/unread"
 	"golang.org/x/oauth2"
 	"honnef.co/go/js/dom"
 )
 
 var document = dom.GetWindow().Document().(dom.HTMLDocument)
 
+// printer is a printer for the language of the page, as chosen by notificationsapp,
+// and the time zone returned by location.
+var printer = i18n.NewPrinter(document.DocumentElement().GetAttribute("lang")).In(location())
+
+// csrfToken is the CSRF token of the page, which is sent with state-changing API requests
+// and included in rendered components. See package csrf.
+var csrfToken = metaContent("notificationsapp-csrf-token")
+
+// baseURI is the base URI of the app, which rendered components link to pages under.
+var baseURI = metaContent("notificationsapp-base-uri")
+
+// undoSupported is whether the server supports undoing mark as read operations.
+var undoSupported = metaContent("notificationsapp-undo") == "supported"
+
 func main() {
 	httpClient := httpClient()
 
-	f := frontend{ns: httpclient.NewNotifications(httpClient, "", "")}
+	f := frontend{ns: httpclient.NewNotificationsAt(httpClient, &url.URL{Path: apiBaseURI()})}
 
-	js.Global.Set("MarkRead", jsutil.Wrap(f.MarkRead))
-	js.Global.Set("MarkAllRead", jsutil.Wrap(f.MarkAllRead))
+	for _, eventType := range []string{"click", "submit", "change"} {
+		document.AddEventListener(eventType, false, f.handleEvent)
+	}
+
+	if toolbar := document.QuerySelector(".Toolbar"); toolbar != nil {
+		// The toolbar needs this script to work, so show it only now.
+		toolbar.RemoveAttribute("hidden")
+	}
+
+	reportTimeZone()
+	if pageTimeZone() == "" {
+		// Absolute times on the page are in the time zone the notifications service
+		// returned them in. Display them in the local time zone instead.
+		localizeTimes()
+	}
+
+	streamEvents()
+}
+
+// apiBaseURI returns the base URI prefix of HTTP API endpoints,
+// as provided by the page. See notificationsapp.Options.APIBaseURI.
+func apiBaseURI() string {
+	meta := document.QuerySelector(`meta[name="notificationsapp-api-base-uri"]`)
+	if meta == nil {
+		return ""
+	}
+	return meta.GetAttribute("content")
+}
+
+// pageTimeZone returns the time zone of absolute times on the page, as chosen
+// by notificationsapp, or empty string if they're in their own time zone.
+// See notificationsapp.Options.Location.
+func pageTimeZone() string {
+	return metaContent("notificationsapp-time-zone")
+}
+
+// metaContent returns the content of the meta element with the specified name,
+// or empty string if there's none.
+func metaContent(name string) string {
+	meta := document.QuerySelector(`meta[name="` + name + `"]`)
+	if meta == nil {
+		return ""
+	}
+	return meta.GetAttribute("content")
+}
+
+// location returns the time zone to display absolute times in.
+// It's the time zone of absolute times on the page if there is one
+// and it can be loaded, or the local time zone otherwise.
+func location() *time.Location {
+	tz := pageTimeZone()
+	if tz == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// reportTimeZone reports the time zone detected by the browser to notificationsapp
+// in the i18n.TimeZoneCookie cookie, so it can display absolute times in it.
+func reportTimeZone() {
+	intl := js.Global.Get("Intl")
+	if intl == js.Undefined {
+		return
+	}
+	tz := intl.Get("DateTimeFormat").New().Call("resolvedOptions").Get("timeZone")
+	if tz == js.Undefined {
+		return
+	}
+	cookies := &http.Request{Header: http.Header{"Cookie": {document.Cookie()}}}
+	if c, err := cookies.Cookie(i18n.TimeZoneCookie); err == nil && c.Value == tz.String() {
+		return
+	}
+	cookie := &http.Cookie{
+		Name:     i18n.TimeZoneCookie,
+		Value:    tz.String(),
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		SameSite: http.SameSiteLaxMode,
+	}
+	document.SetCookie(cookie.String())
+}
+
+// localizeTimes updates the absolute times of time elements on the page
+// to be displayed using printer.
+func localizeTimes() {
+	for _, el := range document.QuerySelectorAll("time[datetime]") {
+		t, err := time.Parse(time.RFC3339, el.GetAttribute("datetime"))
+		if err != nil {
+			log.Println("localizeTimes:", err)
+			continue
+		}
+		el.SetAttribute("title", printer.Time(t))
+	}
 }
 
 // httpClient gives an *http.Client for making API requests.
+// The requests carry the CSRF token of the page.
 func httpClient() *http.Client {
+	client := &http.Client{Transport: csrfTransport{Token: csrfToken, Base: http.DefaultTransport}}
 	cookies := &http.Request{Header: http.Header{"Cookie": {document.Cookie()}}}
 	if accessToken, err := cookies.Cookie("accessToken"); err == nil {
 		// Authenticated client.
 		src := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: accessToken.Value},
 		)
-		return oauth2.NewClient(context.Background(), src)
+		return oauth2.NewClient(context.WithValue(context.Background(), oauth2.HTTPClient, client), src)
 	}
 	// Not authenticated client.
-	return http.DefaultClient
+	return client
+}
+
+// csrfTransport is an http.RoundTripper that sets the CSRF token Token
+// in the csrf.Header header of requests, and makes them via Base.
+type csrfTransport struct {
+	Token string
+	Base  http.RoundTripper
+}
+
+func (t csrfTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request, so set the header on a copy.
+	req2 := new(http.Request)
+	*req2 = *req
+	req2.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		req2.Header[k] = v
+	}
+	req2.Header.Set(csrf.Header, t.Token)
+	return t.Base.RoundTrip(req2)
 }
 
 type frontend struct {
 	ns notifications.Service
 }
 
-func (f frontend) MarkRead(el dom.HTMLElement, repoURI string, threadType string, threadID uint64) {
-	if repoURI == "" && threadType == "" && threadID == 0 {
-		// When user clicks on the notification link, don't perform mark read operation
-		// ourselves, it's expected to be done externally by the service that displays
-		// the notification to the user views. Just make it appear as read, and return.
-		markRead(el)
+// handleEvent handles event if it's for an element with a data-action attribute,
+// by performing the action specified by the attribute. Events are listened for
+// on the document, so that elements added to the page later are handled too.
+func (f frontend) handleEvent(event dom.Event) {
+	if event.Target() == nil {
 		return
 	}
+	el, ok := event.Target().Closest("[data-action]").(dom.HTMLElement)
+	if !ok {
+		return
+	}
+	switch action := el.Dataset()["action"]; event.Type() + " " + action {
+	case "click open":
+		f.Open(el)
+	case "submit mark-read":
+		f.MarkRead(event, el)
+	case "submit mark-all-read":
+		f.MarkAllRead(event, el)
+	case "change select-all":
+		f.SelectAll(el)
+	case "click mark-selected-read":
+		f.MarkSelectedRead(el)
+	case "click mark-unread":
+		f.MarkUnread(event, el)
+	case "click snooze":
+		f.Snooze(event, el)
+	case "click mute":
+		f.Mute(event, el)
+	case "click load-more":
+		f.LoadMore(event, el)
+	}
+}
+
+// Open makes the notification whose link el was clicked appear as read.
+func (frontend) Open(el dom.HTMLElement) {
+	// When user clicks on the notification link, don't perform mark read operation
+	// ourselves, it's expected to be done externally by the service that displays
+	// the notification to the user views. Just make it appear as read.
+	markRead(el)
+}
 
+// MarkRead marks the thread of the notification containing form el as read in place,
+// instead of submitting the form, which would reload the page.
+func (f frontend) MarkRead(event dom.Event, el dom.HTMLElement) {
+	event.PreventDefault()
+	t, err := thread(el)
+	if err != nil {
+		log.Println("MarkRead:", err)
+		return
+	}
+
+	restore := readState(getAncestorByClassName(el, "RepoNotifications"))
 	go func() {
-		err := f.ns.MarkRead(context.Background(), notifications.RepoSpec{URI: repoURI}, threadType, threadID)
+		err := f.ns.MarkRead(context.Background(), notifications.RepoSpec{URI: t.RepoURI}, t.ThreadType, t.ThreadID)
 		if err != nil {
 			log.Println("MarkRead:", err)
 			return
 		}
 		markRead(el)
+		f.showUndoBar(1, []batch.Thread{t}, restore)
 	}()
 }
 
-func (f frontend) MarkAllRead(el dom.HTMLElement, repoURI string) {
+// MarkAllRead marks all notifications in the repository containing form el as read in place,
+// instead of submitting the form, which would reload the page.
+func (f frontend) MarkAllRead(event dom.Event, el dom.HTMLElement) {
+	event.PreventDefault()
+	repo := getAncestorByClassName(el, "RepoNotifications")
+	repoURI := repo.(dom.HTMLElement).Dataset()["repoUri"]
+	n := len(repo.QuerySelectorAll(".multilist-entry:not(.read)")) // Number of unread notifications displayed.
+	if repo.Class().Contains("read") {
+		n = 0
+	}
+	restore := readState(repo)
 	go func() {
 		err := f.ns.MarkAllRead(context.Background(), notifications.RepoSpec{URI: repoURI})
 		if err != nil {
@@ -73,9 +273,439 @@ func (f frontend) MarkAllRead(el dom.HTMLElement, repoURI string) {
 			return
 		}
 		markAllRead(el)
+		f.showUndoBar(n, []batch.Thread{{RepoURI: repoURI}}, restore)
+	}()
+}
+
+// SelectAll selects all displayed notifications if checkbox el is checked,
+// or deselects them otherwise.
+func (frontend) SelectAll(el dom.HTMLElement) {
+	checked := el.(*dom.HTMLInputElement).Checked
+	for _, cb := range document.QuerySelectorAll("input.SelectNotification") {
+		cb.(*dom.HTMLInputElement).Checked = checked
+	}
+}
+
+// MarkSelectedRead marks selected notifications as read in one batch,
+// and reports failures in the toolbar containing element el.
+func (f frontend) MarkSelectedRead(el dom.HTMLElement) {
+	status := getAncestorByClassName(el, "Toolbar").QuerySelector(".Toolbar-status")
+
+	var threads []batch.Thread
+	entries := make(map[batch.Thread]dom.HTMLElement)
+	for _, cb := range document.QuerySelectorAll("input.SelectNotification") {
+		if !cb.(*dom.HTMLInputElement).Checked {
+			continue
+		}
+		entry := getAncestorByClassName(cb, "mark-as-read").(dom.HTMLElement)
+		t, err := thread(entry)
+		if err != nil {
+			log.Println("MarkSelectedRead:", err)
+			continue
+		}
+		threads = append(threads, t)
+		entries[t] = entry
+	}
+	if len(threads) == 0 {
+		return
+	}
+	var repos []dom.Element
+	for _, repo := range document.GetElementsByClassName("RepoNotifications") {
+		repos = append(repos, repo)
+	}
+	restore := readState(repos...)
+
+	go func() {
+		failures, err := batch.MarkRead(context.Background(), f.ns, threads)
+		if err != nil {
+			log.Println("MarkSelectedRead:", err)
+			status.SetTextContent(printer.Text("Failed to mark selected notifications as read."))
+			return
+		}
+		failed := make(map[batch.Thread]string) // Thread -> error message.
+		for _, failure := range failures {
+			failed[failure.Thread] = failure.Error
+		}
+		var marked []batch.Thread
+		for _, t := range threads {
+			entry := entries[t]
+			if msg, ok := failed[t]; ok {
+				entry.Class().Add("failed")
+				entry.SetTitle(msg)
+				continue
+			}
+			entry.Class().Remove("failed")
+			entry.RemoveAttribute("title")
+			entry.QuerySelector("input.SelectNotification").(*dom.HTMLInputElement).Checked = false
+			markRead(entry)
+			marked = append(marked, t)
+		}
+		if len(marked) > 0 {
+			f.showUndoBar(len(marked), marked, restore)
+		}
+		if len(failures) == 0 {
+			status.SetTextContent("")
+			return
+		}
+		status.SetTextContent(printer.Sprintf("Failed to mark %d of %d selected notifications as read.", len(failures), len(threads)))
+	}()
+}
+
+// MarkUnread marks the thread of the notification containing element el as unread.
+func (f frontend) MarkUnread(event dom.Event, el dom.HTMLElement) {
+	event.PreventDefault()
+	m, ok := f.ns.(unread.Marker)
+	if !ok {
+		log.Println("MarkUnread: not supported")
+		return
+	}
+	t, err := thread(el)
+	if err != nil {
+		log.Println("MarkUnread:", err)
+		return
+	}
+	go func() {
+		err := m.MarkUnread(context.Background(), notifications.RepoSpec{URI: t.RepoURI}, t.ThreadType, t.ThreadID)
+		if err != nil {
+			log.Println("MarkUnread:", err)
+			return
+		}
+		markUnread(el)
+	}()
+}
+
+// Snooze snoozes the thread of the notification containing element el for as long as
+// the choice of el says, in local time, and removes the notification from the page.
+func (f frontend) Snooze(event dom.Event, el dom.HTMLElement) {
+	event.PreventDefault()
+	s, ok := f.ns.(snooze.Snoozer)
+	if !ok {
+		log.Println("Snooze: not supported")
+		return
+	}
+	c, ok := snooze.ChoiceByID(el.Dataset()["choice"])
+	if !ok {
+		log.Printf("Snooze: unknown choice %q", el.Dataset()["choice"])
+		return
+	}
+	t, err := thread(el)
+	if err != nil {
+		log.Println("Snooze:", err)
+		return
+	}
+	until := c.Until(time.Now())
+	go func() {
+		err := s.Snooze(context.Background(), notifications.RepoSpec{URI: t.RepoURI}, t.ThreadType, t.ThreadID, until)
+		if err != nil {
+			log.Println("Snooze:", err)
+			return
+		}
+		entry := getAncestorByClassName(el, "mark-as-read")
+		entry.ParentNode().RemoveChild(entry)
 	}()
 }
 
+// Mute mutes the thread of the notification containing element el,
+// and removes the notification from the page. The user can undo it via the undo bar.
+func (f frontend) Mute(event dom.Event, el dom.HTMLElement) {
+	event.PreventDefault()
+	m, ok := f.ns.(mute.Muter)
+	if !ok {
+		log.Println("Mute: not supported")
+		return
+	}
+	t, err := thread(el)
+	if err != nil {
+		log.Println("Mute:", err)
+		return
+	}
+	go func() {
+		repo := notifications.RepoSpec{URI: t.RepoURI}
+		err := m.Mute(context.Background(), repo, t.ThreadType, t.ThreadID)
+		if err != nil {
+			log.Println("Mute:", err)
+			return
+		}
+		entry := getAncestorByClassName(el, "mark-as-read")
+		parent, next := entry.ParentNode(), entry.NextSibling()
+		parent.RemoveChild(entry)
+		showUndoBar(printer.Text("Muted thread."), func(ctx context.Context) error {
+			err := m.Unmute(ctx, repo, t.ThreadType, t.ThreadID)
+			if err != nil {
+				log.Println("Unmute:", err)
+			}
+			return err
+		}, func() {
+			parent.InsertBefore(entry, next)
+		})
+	}()
+}
+
+// LoadMore loads the next page of notifications linked to by element el,
+// and inserts them into the page.
+func (f frontend) LoadMore(event dom.Event, el dom.HTMLElement) {
+	event.PreventDefault()
+
+	u, err := url.Parse(el.GetAttribute("href"))
+	if err != nil {
+		log.Println("LoadMore: url.Parse:", err)
+		return
+	}
+	all, filter := component.ParseQuery(u.Query())
+	opt := notifications.ListOptions{All: all}
+	if repoURI, ok := el.Dataset()["repoUri"]; ok {
+		opt.Repo = &notifications.RepoSpec{URI: repoURI}
+	}
+	pageSize, _ := strconv.Atoi(el.Dataset()["pageSize"])
+	p := pagination.Options{Size: pageSize, Cursor: u.Query().Get("cursor")}
+
+	go func() {
+		page, err := pagination.List(context.Background(), f.ns, opt, filter, p)
+		if err != nil {
+			log.Println("LoadMore:", err)
+			return
+		}
+		loadMore := getAncestorByClassName(el, "LoadMore").(dom.HTMLElement)
+		appendNotifications(page.Notifications)
+		if page.NextCursor == "" {
+			loadMore.ParentNode().RemoveChild(loadMore)
+			return
+		}
+		q := u.Query()
+		q.Set("cursor", page.NextCursor)
+		u.RawQuery = q.Encode()
+		el.SetAttribute("href", u.String())
+	}()
+}
+
+// appendNotifications appends notifications ns to the page. Notifications from repositories
+// that are already displayed are appended to them, others are appended to the end of the list.
+func appendNotifications(ns notifications.Notifications) {
+	existing := make(map[string]dom.HTMLElement) // Repo URI -> RepoNotifications element.
+	for _, el := range document.GetElementsByClassName("RepoNotifications") {
+		el := el.(dom.HTMLElement)
+		existing[el.Dataset()["repoUri"]] = el
+	}
+
+	var added []*component.RepoNotifications // Repos not already displayed, in order of appearance.
+	addedIndex := make(map[string]int)
+	for _, n := range ns {
+		if el, ok := existing[n.RepoSpec.URI]; ok {
+			insertHTML(el, "beforeend", htmlg.RenderComponentsString(component.Notification{Notification: n, Printer: printer, CSRFToken: csrfToken}))
+			if !n.Read {
+				el.Class().Remove("read")
+			}
+			continue
+		}
+		i, ok := addedIndex[n.RepoSpec.URI]
+		if !ok {
+			i = len(added)
+			addedIndex[n.RepoSpec.URI] = i
+			added = append(added, &component.RepoNotifications{Repo: n.RepoSpec, BaseURI: baseURI, Printer: printer, CSRFToken: csrfToken})
+		}
+		added[i].Notifications = append(added[i].Notifications, component.Notification{Notification: n, Printer: printer, CSRFToken: csrfToken})
+	}
+	list := document.QuerySelector(".NotificationsByRepo")
+	if list == nil {
+		return
+	}
+	for _, rn := range added {
+		insertHTML(list, "beforeend", htmlg.RenderComponentsString(rn))
+	}
+}
+
+// insertHTML parses html and inserts the resulting nodes
+// into the DOM tree at the specified position relative to el.
+func insertHTML(el dom.Element, position string, html string) {
+	el.Underlying().Call("insertAdjacentHTML", position, html)
+}
+
+// streamEvents subscribes to live events about notifications,
+// and updates the displayed notifications in place as they happen.
+func streamEvents() {
+	if js.Global.Get("EventSource") == js.Undefined {
+		return
+	}
+	q, _ := url.ParseQuery(strings.TrimPrefix(dom.GetWindow().Location().Search, "?"))
+	all, filter := component.ParseQuery(q)
+
+	source := js.Global.Get("EventSource").New(apiBaseURI() + httproute.Stream)
+	listen := func(eventType string, handle func(notifications.Notification)) {
+		source.Call("addEventListener", eventType, func(event *js.Object) {
+			var n notifications.Notification
+			err := json.Unmarshal([]byte(event.Get("data").String()), &n)
+			if err != nil {
+				log.Printf("streamEvents: %s event: %v", eventType, err)
+				return
+			}
+			handle(n)
+		})
+	}
+	listen(stream.Notify, func(n notifications.Notification) {
+		if (n.Read && !all) || !filter.Match(n) {
+			return
+		}
+		upsertNotification(n)
+	})
+	listen(stream.Read, func(n notifications.Notification) {
+		for _, el := range findNotifications(n.RepoSpec.URI, n.ThreadType, n.ThreadID) {
+			markRead(el)
+		}
+	})
+	listen(stream.Unread, func(n notifications.Notification) {
+		els := findNotifications(n.RepoSpec.URI, n.ThreadType, n.ThreadID)
+		for _, el := range els {
+			markUnread(el)
+		}
+		if len(els) == 0 && n.Title != "" && filter.Match(n) {
+			// The notification isn't displayed, e.g., because it was read
+			// when the page was loaded. Display it now that it's unread.
+			upsertNotification(n)
+		}
+	})
+}
+
+// findNotifications finds displayed notifications in the specified thread.
+// If threadType and threadID are zero, it finds all notifications in the repository.
+func findNotifications(repoURI string, threadType string, threadID uint64) []dom.HTMLElement {
+	var els []dom.HTMLElement
+	for _, el := range document.GetElementsByClassName("multilist-entry") {
+		el := el.(dom.HTMLElement)
+		d := el.Dataset()
+		if d["repoUri"] != repoURI {
+			continue
+		}
+		if (threadType != "" || threadID != 0) &&
+			(d["threadType"] != threadType || d["threadId"] != strconv.FormatUint(threadID, 10)) {
+			continue
+		}
+		els = append(els, el)
+	}
+	return els
+}
+
+// upsertNotification displays notification n as the most recent one,
+// replacing an existing notification in the same thread, if any.
+func upsertNotification(n notifications.Notification) {
+	for _, el := range findNotifications(n.RepoSpec.URI, n.ThreadType, n.ThreadID) {
+		el.ParentNode().RemoveChild(el)
+	}
+
+	var repo dom.HTMLElement
+	for _, el := range document.GetElementsByClassName("RepoNotifications") {
+		if el := el.(dom.HTMLElement); el.Dataset()["repoUri"] == n.RepoSpec.URI {
+			repo = el
+			break
+		}
+	}
+	list := document.QuerySelector(".NotificationsByRepo")
+	switch {
+	case repo != nil:
+		if placeholder := repo.QuerySelector(".NoNotifications"); placeholder != nil {
+			placeholder.ParentNode().RemoveChild(placeholder)
+		}
+		insertHTML(repo.QuerySelector(".list-entry-header"), "afterend", htmlg.RenderComponentsString(component.Notification{Notification: n, Printer: printer, CSRFToken: csrfToken}))
+		if !n.Read {
+			repo.Class().Remove("read")
+		}
+		if list != nil {
+			// Move the repository to the top of the list.
+			list.InsertBefore(repo, list.FirstChild())
+		}
+	case list != nil:
+		if placeholder := list.QuerySelector(".NoNotifications"); placeholder != nil {
+			list.RemoveChild(placeholder.ParentNode())
+		}
+		rn := component.RepoNotifications{
+			Repo:          n.RepoSpec,
+			Notifications: []component.Notification{{Notification: n, Printer: printer, CSRFToken: csrfToken}},
+			BaseURI:       baseURI,
+			Printer:       printer,
+			CSRFToken:     csrfToken,
+		}
+		insertHTML(list, "afterbegin", htmlg.RenderComponentsString(rn))
+	}
+}
+
+// undoBarTimeout is how long the undo bar is displayed for.
+const undoBarTimeout = 10 * time.Second
+
+// showUndoBar shows a bar for undoing the marking of n notifications in threads as read,
+// replacing the existing one, if any. If the user chooses to undo, the mark as read
+// operations are undone, and restore is called to restore the displayed read state.
+// It does nothing if the server doesn't support undoing them.
+// See undo.Undoer for the meaning of threads.
+func (f frontend) showUndoBar(n int, threads []batch.Thread, restore func()) {
+	u, ok := f.ns.(undo.Undoer)
+	if !ok || !undoSupported {
+		return
+	}
+	text := printer.Plural(n, "Marked %d notification as read.", "Marked %d notifications as read.")
+	showUndoBar(text, func(ctx context.Context) error {
+		err := u.UndoMarkRead(ctx, threads)
+		if err != nil {
+			log.Println("UndoMarkRead:", err)
+		}
+		return err
+	}, restore)
+}
+
+// showUndoBar shows a bar with text about an action and a link to undo it,
+// replacing the existing one, if any. If the user chooses to undo, undo is called,
+// and then restore, if undo succeeded.
+func showUndoBar(text string, undo func(context.Context) error, restore func()) {
+	if bar := document.QuerySelector(".UndoBar"); bar != nil {
+		bar.ParentNode().RemoveChild(bar)
+	}
+	insertHTML(document.Body(), "beforeend", htmlg.RenderComponentsString(component.UndoBar{Text: text, Printer: printer}))
+	bar := document.QuerySelector(".UndoBar")
+	remove := func() {
+		if parent := bar.ParentNode(); parent != nil {
+			parent.RemoveChild(bar)
+		}
+	}
+	timer := time.AfterFunc(undoBarTimeout, remove)
+	bar.QuerySelector(".UndoBar-undo").AddEventListener("click", false, func(event dom.Event) {
+		event.PreventDefault()
+		timer.Stop()
+		go func() {
+			err := undo(context.Background())
+			if err != nil {
+				bar.QuerySelector(".UndoBar-text").SetTextContent(printer.Text("Couldn't undo."))
+				time.AfterFunc(undoBarTimeout, remove)
+				return
+			}
+			restore()
+			remove()
+		}()
+	})
+}
+
+// readState records the displayed read state of elements els and notifications within them,
+// and returns a func that restores it.
+func readState(els ...dom.Element) (restore func()) {
+	type state struct {
+		el   dom.Element
+		read bool
+	}
+	var states []state
+	for _, el := range els {
+		states = append(states, state{el, el.Class().Contains("read")})
+		for _, n := range el.QuerySelectorAll(".mark-as-read") {
+			states = append(states, state{n, n.Class().Contains("read")})
+		}
+	}
+	return func() {
+		for _, s := range states {
+			if s.read {
+				s.el.Class().Add("read")
+			} else {
+				s.el.Class().Remove("read")
+			}
+		}
+	}
+}
+
 // markRead marks the notification containing element el as read.
 func markRead(el dom.HTMLElement) {
 	// Mark this particular notification as read.
@@ -95,6 +725,33 @@ func markAllRead(el dom.HTMLElement) {
 	repo.(dom.HTMLElement).Class().Add("read")
 }
 
+// markUnread marks the notification containing element el as unread.
+func markUnread(el dom.HTMLElement) {
+	repo := getAncestorByClassName(el, "RepoNotifications").(dom.HTMLElement)
+	if repo.Class().Contains("read") {
+		// All notifications within the parent RepoNotifications were displayed as read
+		// via the parent. Mark them as read individually before marking this one as unread.
+		for _, n := range repo.QuerySelectorAll(".mark-as-read") {
+			n.(dom.HTMLElement).Class().Add("read")
+		}
+		repo.Class().Remove("read")
+	}
+
+	// Mark this particular notification as unread.
+	getAncestorByClassName(el, "mark-as-read").(dom.HTMLElement).Class().Remove("read")
+}
+
+// thread returns the thread of the notification containing element el,
+// as specified by its data-* attributes.
+func thread(el dom.Element) (batch.Thread, error) {
+	d := getAncestorByClassName(el, "multilist-entry").(dom.HTMLElement).Dataset()
+	threadID, err := strconv.ParseUint(d["threadId"], 10, 64)
+	if err != nil {
+		return batch.Thread{}, err
+	}
+	return batch.Thread{RepoURI: d["repoUri"], ThreadType: d["threadType"], ThreadID: threadID}, nil
+}
+
 func getAncestorByClassName(el dom.Element, class string) dom.Element {
 	for ; el != nil && !el.Class().Contains(class); el = el.ParentElement() {
 	}
@@ -1,3 +1,14 @@
 module github.com/shurcooL/notificationsapp
 
-go 1.19
+go 1.26.0
+
+require (
+	github.com/dustin/go-humanize v1.1.0
+	github.com/shurcooL/htmlg v0.0.0-20170918183704-d01228ac9e50
+	github.com/shurcooL/httperror v0.0.0-20170206035902-86b7830d14cc
+	github.com/shurcooL/httpgzip v0.0.0-20180522190206-b1c53ac65af9
+	github.com/shurcooL/notifications v0.0.0-20181007000457-627ab5aea122
+	github.com/shurcooL/octicon v0.0.0-20181028054416-fa4f57f9efb2
+	github.com/shurcooL/users v0.0.0-20180125191416-49c67e49c537
+	golang.org/x/oauth2 v0.37.0
+)
@@ -0,0 +1,56 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/apierror"
+	"github.com/shurcooL/notificationsapp/httpclient"
+)
+
+func TestError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Query().Get("RepoURI") {
+		case "missing":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":404,"message":"Not Found"}`))
+		case "invalid":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":400,"message":"invalid","field":"ThreadID"}`))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"code":403,"message":"Forbidden"}`))
+		}
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := httpclient.NewNotificationsAt(nil, u)
+
+	err = c.MarkAllRead(context.Background(), notifications.RepoSpec{URI: "missing"})
+	var e *apierror.Error
+	if !errors.Is(err, os.ErrNotExist) || !errors.As(err, &e) || e.Message != "Not Found" {
+		t.Errorf("missing: got %#v, want a not exist error with the envelope", err)
+	}
+
+	err = c.MarkAllRead(context.Background(), notifications.RepoSpec{URI: "invalid"})
+	if !errors.As(err, &e) || e.Code != http.StatusBadRequest || e.Field != "ThreadID" {
+		t.Errorf("invalid: got %#v, want a 400 error about ThreadID", err)
+	}
+
+	err = c.MarkAllRead(context.Background(), notifications.RepoSpec{URI: "other"})
+	if !errors.Is(err, os.ErrPermission) || !errors.As(err, &e) || e.Message != "Forbidden" {
+		t.Errorf("other: got %#v, want a permission error with the envelope", err)
+	}
+}
@@ -1,16 +1,32 @@
 // Package httpclient contains notifications.Service implementation over HTTP.
+//
+// Requests that the server responds to with an error status code fail with
+// an *apierror.Error decoded from the JSON error envelope, so errors.As can be
+// used to get its message and field. It matches os.ErrNotExist for 404 Not Found
+// and os.ErrPermission for 403 Forbidden, so errors.Is can be used to check for
+// them, the same way notificationsapp does on the server side. os.IsNotExist and
+// os.IsPermission only recognize errors from package os, so they can't be used.
 package httpclient
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/apierror"
+	"github.com/shurcooL/notificationsapp/batch"
+	"github.com/shurcooL/notificationsapp/filter"
 	"github.com/shurcooL/notificationsapp/httproute"
+	"github.com/shurcooL/notificationsapp/pagination"
 	"github.com/shurcooL/users"
 	"golang.org/x/net/context/ctxhttp"
 )
@@ -19,12 +35,21 @@ import (
 // If a nil httpClient is provided, http.DefaultClient will be used.
 // scheme and host can be empty strings to target local service.
 func NewNotifications(httpClient *http.Client, scheme, host string) notifications.Service {
+	return NewNotificationsAt(httpClient, &url.URL{
+		Scheme: scheme,
+		Host:   host,
+	})
+}
+
+// NewNotificationsAt creates a client that implements notifications.Service remotely over HTTP,
+// using API endpoints at baseURL.Path + httproute paths. E.g., if baseURL is "https://example.org/notifications",
+// the List endpoint is at "https://example.org/notifications/api/notifications/list".
+// If a nil httpClient is provided, http.DefaultClient will be used.
+// baseURL scheme and host can be empty to target local service.
+func NewNotificationsAt(httpClient *http.Client, baseURL *url.URL) notifications.Service {
 	return &notificationsClient{
-		client: httpClient,
-		baseURL: &url.URL{
-			Scheme: scheme,
-			Host:   host,
-		},
+		client:  httpClient,
+		baseURL: baseURL,
 	}
 }
 
@@ -32,46 +57,170 @@ func NewNotifications(httpClient *http.Client, scheme, host string) notification
 type notificationsClient struct {
 	client  *http.Client // HTTP client for API requests. If nil, http.DefaultClient should be used.
 	baseURL *url.URL     // Base URL for API requests.
+
+	cacheMu sync.Mutex
+	cache   map[string]cachedResponse // Cached responses to GET requests, keyed by URL.
+}
+
+// cachedResponse is a cached 200 OK response to a GET request.
+type cachedResponse struct {
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+// maxCachedResponses is the maximum number of responses that are cached.
+const maxCachedResponses = 64
+
+// endpoint returns the URL of API endpoint u, whose path is an httproute path.
+func (n *notificationsClient) endpoint(u url.URL) string {
+	u.Path = strings.TrimSuffix(n.baseURL.Path, "/") + u.Path
+	return n.baseURL.ResolveReference(&u).String()
+}
+
+// get makes a GET request to rawURL, and returns the header and body
+// of a 200 OK response. If a response to an earlier request is cached,
+// it's revalidated, and reused if the server responds with 304 Not Modified.
+func (n *notificationsClient) get(ctx context.Context, rawURL string) (http.Header, []byte, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	n.cacheMu.Lock()
+	cached, ok := n.cache[rawURL]
+	n.cacheMu.Unlock()
+	if ok {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	resp, err := ctxhttp.Do(ctx, n.client, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && ok:
+		return cached.header, cached.body, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, nil, responseError(resp)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		n.cacheMu.Lock()
+		if n.cache == nil {
+			n.cache = make(map[string]cachedResponse)
+		}
+		for k := range n.cache {
+			if len(n.cache) < maxCachedResponses {
+				break
+			}
+			delete(n.cache, k)
+		}
+		n.cache[rawURL] = cachedResponse{etag: etag, header: resp.Header, body: body}
+		n.cacheMu.Unlock()
+	}
+	return resp.Header, body, nil
+}
+
+// responseError returns the error that non-200 OK response resp reports.
+// It's made from the *apierror.Error decoded from the JSON error envelope
+// in the body of resp, or from its status code and body if there's no envelope.
+// See package documentation.
+func responseError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+	e := &apierror.Error{}
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt != "application/json" ||
+		json.Unmarshal(body, e) != nil || e.Code == 0 {
+		e = &apierror.Error{Code: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	return e
 }
 
 func (n *notificationsClient) List(ctx context.Context, opt notifications.ListOptions) (notifications.Notifications, error) {
+	return n.ListFiltered(ctx, opt, filter.Options{})
+}
+
+// ListFiltered implements filter.Lister.
+func (n *notificationsClient) ListFiltered(ctx context.Context, opt notifications.ListOptions, f filter.Options) (notifications.Notifications, error) {
+	page, err := n.ListPage(ctx, opt, f, pagination.Options{})
+	return page.Notifications, err
+}
+
+// ListPage implements pagination.Lister.
+func (n *notificationsClient) ListPage(ctx context.Context, opt notifications.ListOptions, f filter.Options, p pagination.Options) (pagination.Page, error) {
 	v := url.Values{} // TODO: Automate this conversion process.
 	if opt.Repo != nil {
 		v.Set("RepoURI", opt.Repo.URI)
-		if opt.All {
-			v.Set("All", "1")
-		}
+	}
+	if opt.All {
+		v.Set("All", "1")
+	}
+	if f.Participating {
+		v.Set("Participating", "1")
+	}
+	if f.Mentioned {
+		v.Set("Mentioned", "1")
+	}
+	if f.ThreadType != "" {
+		v.Set("ThreadType", f.ThreadType)
+	}
+	if f.Actor != "" {
+		v.Set("Actor", f.Actor)
+	}
+	if f.Query != "" {
+		v.Set("Query", f.Query)
+	}
+	if p.Size != 0 {
+		v.Set("PageSize", fmt.Sprint(p.Size))
+	}
+	if p.Cursor != "" {
+		v.Set("Cursor", p.Cursor)
 	}
 	u := url.URL{
 		Path:     httproute.List,
 		RawQuery: v.Encode(),
 	}
-	resp, err := ctxhttp.Get(ctx, n.client, n.baseURL.ResolveReference(&u).String())
+	header, body, err := n.get(ctx, n.endpoint(u))
 	if err != nil {
-		return nil, err
+		return pagination.Page{}, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("did not get acceptable status code: %v body: %q", resp.Status, body)
+	var page pagination.Page
+	err = json.Unmarshal(body, &page.Notifications)
+	if err != nil {
+		return pagination.Page{}, err
 	}
-	var ns notifications.Notifications
-	err = json.NewDecoder(resp.Body).Decode(&ns)
-	return ns, err
+	page.NextCursor = nextCursor(header)
+	return page, nil
+}
+
+// nextCursor returns the cursor of the next page linked
+// to from header h, or empty string if there isn't one.
+func nextCursor(h http.Header) string {
+	for _, link := range strings.Split(h.Get("Link"), ",") {
+		// E.g., </api/notifications/list?Cursor=abc>; rel="next".
+		link = strings.TrimSpace(link)
+		i := strings.Index(link, ">")
+		if !strings.HasPrefix(link, "<") || i == -1 || !strings.Contains(link[i:], `rel="next"`) {
+			continue
+		}
+		u, err := url.Parse(link[1:i])
+		if err != nil {
+			return ""
+		}
+		return u.Query().Get("Cursor")
+	}
+	return ""
 }
 
 func (n *notificationsClient) Count(ctx context.Context, opt interface{}) (uint64, error) {
-	resp, err := ctxhttp.Get(ctx, n.client, n.baseURL.ResolveReference(&url.URL{Path: httproute.Count}).String())
+	_, body, err := n.get(ctx, n.endpoint(url.URL{Path: httproute.Count}))
 	if err != nil {
 		return 0, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return 0, fmt.Errorf("did not get acceptable status code: %v body: %q", resp.Status, body)
-	}
 	var u uint64
-	err = json.NewDecoder(resp.Body).Decode(&u)
+	err = json.Unmarshal(body, &u)
 	return u, err
 }
 
@@ -84,14 +233,13 @@ func (n *notificationsClient) MarkRead(ctx context.Context, repo notifications.R
 			"ThreadID":   {fmt.Sprint(threadID)},
 		}.Encode(),
 	}
-	resp, err := ctxhttp.Post(ctx, n.client, n.baseURL.ResolveReference(&u).String(), "", nil)
+	resp, err := ctxhttp.Post(ctx, n.client, n.endpoint(u), "", nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("did not get acceptable status code: %v body: %q", resp.Status, body)
+		return responseError(resp)
 	}
 	return nil
 }
@@ -103,22 +251,182 @@ func (n *notificationsClient) MarkAllRead(ctx context.Context, repo notification
 			"RepoURI": {repo.URI},
 		}.Encode(),
 	}
-	resp, err := ctxhttp.Post(ctx, n.client, n.baseURL.ResolveReference(&u).String(), "", nil)
+	resp, err := ctxhttp.Post(ctx, n.client, n.endpoint(u), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// MarkReadBatch implements batch.MarkReader.
+func (n *notificationsClient) MarkReadBatch(ctx context.Context, threads []batch.Thread) ([]batch.Failure, error) {
+	body, err := json.Marshal(threads)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ctxhttp.Post(ctx, n.client, n.endpoint(url.URL{Path: httproute.MarkReadBatch}), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	var failures []batch.Failure
+	err = json.NewDecoder(resp.Body).Decode(&failures)
+	return failures, err
+}
+
+// MarkUnread implements unread.Marker.
+func (n *notificationsClient) MarkUnread(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error {
+	u := url.URL{
+		Path: httproute.MarkUnread,
+		RawQuery: url.Values{
+			"RepoURI":    {repo.URI},
+			"ThreadType": {threadType},
+			"ThreadID":   {fmt.Sprint(threadID)},
+		}.Encode(),
+	}
+	resp, err := ctxhttp.Post(ctx, n.client, n.endpoint(u), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// UndoMarkRead implements undo.Undoer.
+func (n *notificationsClient) UndoMarkRead(ctx context.Context, threads []batch.Thread) error {
+	body, err := json.Marshal(threads)
+	if err != nil {
+		return err
+	}
+	resp, err := ctxhttp.Post(ctx, n.client, n.endpoint(url.URL{Path: httproute.UndoMarkRead}), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// Snooze implements snooze.Snoozer.
+func (n *notificationsClient) Snooze(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64, until time.Time) error {
+	u := url.URL{
+		Path: httproute.Snooze,
+		RawQuery: url.Values{
+			"RepoURI":    {repo.URI},
+			"ThreadType": {threadType},
+			"ThreadID":   {fmt.Sprint(threadID)},
+			"Until":      {until.Format(time.RFC3339)},
+		}.Encode(),
+	}
+	resp, err := ctxhttp.Post(ctx, n.client, n.endpoint(u), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// Mute implements mute.Muter.
+func (n *notificationsClient) Mute(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error {
+	u := url.URL{
+		Path: httproute.Mute,
+		RawQuery: url.Values{
+			"RepoURI":    {repo.URI},
+			"ThreadType": {threadType},
+			"ThreadID":   {fmt.Sprint(threadID)},
+		}.Encode(),
+	}
+	resp, err := ctxhttp.Post(ctx, n.client, n.endpoint(u), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// Unmute implements mute.Muter.
+func (n *notificationsClient) Unmute(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error {
+	u := url.URL{
+		Path: httproute.Unmute,
+		RawQuery: url.Values{
+			"RepoURI":    {repo.URI},
+			"ThreadType": {threadType},
+			"ThreadID":   {fmt.Sprint(threadID)},
+		}.Encode(),
+	}
+	resp, err := ctxhttp.Post(ctx, n.client, n.endpoint(u), "", nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("did not get acceptable status code: %v body: %q", resp.Status, body)
+		return responseError(resp)
 	}
 	return nil
 }
 
-func (notificationsClient) Subscribe(_ context.Context, repo notifications.RepoSpec, threadType string, threadID uint64, subscribers []users.UserSpec) error {
-	return fmt.Errorf("Subscribe: not implemented")
+func (n *notificationsClient) Subscribe(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64, subscribers []users.UserSpec) error {
+	body, err := json.Marshal(subscribers)
+	if err != nil {
+		return err
+	}
+	u := url.URL{
+		Path: httproute.Subscribe,
+		RawQuery: url.Values{
+			"RepoURI":    {repo.URI},
+			"ThreadType": {threadType},
+			"ThreadID":   {fmt.Sprint(threadID)},
+		}.Encode(),
+	}
+	resp, err := ctxhttp.Post(ctx, n.client, n.endpoint(u), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
 }
 
-func (notificationsClient) Notify(_ context.Context, repo notifications.RepoSpec, threadType string, threadID uint64, nr notifications.NotificationRequest) error {
-	return fmt.Errorf("Notify: not implemented")
+func (n *notificationsClient) Notify(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64, nr notifications.NotificationRequest) error {
+	body, err := json.Marshal(nr)
+	if err != nil {
+		return err
+	}
+	u := url.URL{
+		Path: httproute.Notify,
+		RawQuery: url.Values{
+			"RepoURI":    {repo.URI},
+			"ThreadType": {threadType},
+			"ThreadID":   {fmt.Sprint(threadID)},
+		}.Encode(),
+	}
+	resp, err := ctxhttp.Post(ctx, n.client, n.endpoint(u), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
 }
@@ -0,0 +1,143 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp"
+	"github.com/shurcooL/notificationsapp/apierror"
+	"github.com/shurcooL/notificationsapp/httpclient"
+	"github.com/shurcooL/notificationsapp/httphandler"
+	"github.com/shurcooL/notificationsapp/httproute"
+	"github.com/shurcooL/users"
+)
+
+func TestNotify(t *testing.T) {
+	var got []notifications.NotificationRequest
+	api := httphandler.Notifications{
+		Notifications: notifyService{notify: func(nr notifications.NotificationRequest) { got = append(got, nr) }},
+		// Notify isn't checked for a CSRF token, only authorized by AuthorizeNotify.
+		SkipCSRFCheck: func(*http.Request) bool { return false },
+		AuthorizeNotify: func(req *http.Request) error {
+			if req.Header.Get("Authorization") != "Bearer producer" {
+				return os.ErrPermission
+			}
+			return nil
+		},
+	}
+	mux := http.NewServeMux()
+	mux.Handle(httproute.Notify, notificationsapp.APIHandler(api.Notify, nil, notificationsapp.Options{}))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nr := notifications.NotificationRequest{Title: "Issue opened", HTMLURL: "https://example.org/issues/1"}
+	repo := notifications.RepoSpec{URI: "example.org/repo"}
+
+	producer := httpclient.NewNotificationsAt(&http.Client{Transport: authTransport("Bearer producer")}, u)
+	err = producer.Notify(context.Background(), repo, "Issue", 1, nr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Title != nr.Title || got[0].HTMLURL != nr.HTMLURL {
+		t.Errorf("got %+v, want one %+v", got, nr)
+	}
+
+	for _, tc := range []struct {
+		repo       notifications.RepoSpec
+		threadType string
+		field      string
+	}{
+		{repo: notifications.RepoSpec{}, threadType: "Issue", field: "RepoURI"},
+		{repo: repo, threadType: "", field: "ThreadType"},
+	} {
+		err = producer.Notify(context.Background(), tc.repo, tc.threadType, 1, nr)
+		var e *apierror.Error
+		if !errors.As(err, &e) || e.Code != http.StatusBadRequest || e.Field != tc.field {
+			t.Errorf("got %v, want 400 Bad Request error for field %q", err, tc.field)
+		}
+	}
+	if len(got) != 1 {
+		t.Errorf("got %v notifications, want 1", len(got))
+	}
+
+	other := httpclient.NewNotificationsAt(&http.Client{Transport: authTransport("Bearer other")}, u)
+	err = other.Notify(context.Background(), repo, "Issue", 1, nr)
+	if !errors.Is(err, os.ErrPermission) {
+		t.Errorf("got %v, want an error that is os.ErrPermission", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	var got []users.UserSpec
+	api := httphandler.Notifications{
+		Notifications: notifyService{subscribe: func(subscribers []users.UserSpec) { got = append(got, subscribers...) }},
+		AuthorizeNotify: func(req *http.Request) error {
+			if req.Header.Get("Authorization") != "Bearer producer" {
+				return os.ErrPermission
+			}
+			return nil
+		},
+	}
+	mux := http.NewServeMux()
+	mux.Handle(httproute.Subscribe, notificationsapp.APIHandler(api.Subscribe, nil, notificationsapp.Options{}))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscribers := []users.UserSpec{{ID: 1, Domain: "example.org"}, {ID: 2, Domain: "example.org"}}
+	repo := notifications.RepoSpec{URI: "example.org/repo"}
+
+	producer := httpclient.NewNotificationsAt(&http.Client{Transport: authTransport("Bearer producer")}, u)
+	err = producer.Subscribe(context.Background(), repo, "Issue", 1, subscribers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, subscribers) {
+		t.Errorf("got subscribers %v, want %v", got, subscribers)
+	}
+
+	other := httpclient.NewNotificationsAt(&http.Client{Transport: authTransport("Bearer other")}, u)
+	err = other.Subscribe(context.Background(), repo, "Issue", 1, subscribers)
+	if !errors.Is(err, os.ErrPermission) {
+		t.Errorf("got %v, want an error that is os.ErrPermission", err)
+	}
+}
+
+type notifyService struct {
+	notifications.Service
+	notify    func(notifications.NotificationRequest)
+	subscribe func([]users.UserSpec)
+}
+
+func (s notifyService) Notify(_ context.Context, _ notifications.RepoSpec, _ string, _ uint64, nr notifications.NotificationRequest) error {
+	s.notify(nr)
+	return nil
+}
+
+func (s notifyService) Subscribe(_ context.Context, _ notifications.RepoSpec, _ string, _ uint64, subscribers []users.UserSpec) error {
+	s.subscribe(subscribers)
+	return nil
+}
+
+// authTransport is an http.RoundTripper that sets the Authorization header of requests.
+type authTransport string
+
+func (t authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req2 := req.Clone(req.Context())
+	req2.Header.Set("Authorization", string(t))
+	return http.DefaultTransport.RoundTrip(req2)
+}
@@ -2,18 +2,62 @@
 package httphandler
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/shurcooL/httperror"
 	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/apierror"
+	"github.com/shurcooL/notificationsapp/batch"
+	"github.com/shurcooL/notificationsapp/csrf"
+	"github.com/shurcooL/notificationsapp/filter"
+	"github.com/shurcooL/notificationsapp/internal/unwrap"
+	"github.com/shurcooL/notificationsapp/mute"
+	"github.com/shurcooL/notificationsapp/pagination"
+	"github.com/shurcooL/notificationsapp/snooze"
+	"github.com/shurcooL/notificationsapp/stream"
+	"github.com/shurcooL/notificationsapp/undo"
+	"github.com/shurcooL/notificationsapp/unread"
+	"github.com/shurcooL/notificationsapp/validator"
+	"github.com/shurcooL/users"
 )
 
 // Notifications is an API handler for notifications.Service.
-// It returns errors compatible with httperror package.
+// It returns errors compatible with httperror package. Errors about
+// invalid request parameters wrap apierror.FieldError.
+//
+// Optional interfaces such as snooze.Snoozer and mute.Muter are looked up
+// in Notifications, and then in the services it wraps. A service that wraps
+// another one can make it available via an Unwrap() notifications.Service method.
+//
+// State-changing requests must carry a valid CSRF token, as described in package csrf,
+// or they're rejected with 403 Forbidden, unless they're exempt via SkipCSRFCheck.
+// Notify and Subscribe are the exceptions; they're authorized by AuthorizeNotify alone.
 type Notifications struct {
 	Notifications notifications.Service
+
+	// Events is an optional source of events about notifications.
+	// If nil, Stream responds with 404 Not Found.
+	Events stream.Source
+
+	// SkipCSRFCheck, if not nil, reports whether state-changing request req
+	// doesn't need to carry a CSRF token. It's meant for API clients that
+	// don't authenticate with cookies. If nil, csrf.TokenAuthenticated is used,
+	// so requests that authenticate with a bearer access token don't need to carry one.
+	SkipCSRFCheck func(req *http.Request) bool
+
+	// AuthorizeNotify, if not nil, returns an error if Notify or Subscribe request req
+	// isn't made by a trusted producer of notifications, which is returned
+	// from the handler (e.g., a permission error, which results in 403 Forbidden).
+	// If nil, Notify and Subscribe respond with 403 Forbidden to all requests.
+	// These requests aren't checked for a CSRF token, since producers
+	// aren't browsers, so AuthorizeNotify must not rely on cookies alone.
+	AuthorizeNotify func(req *http.Request) error
 }
 
 func (h Notifications) List(w http.ResponseWriter, req *http.Request) error {
@@ -23,16 +67,44 @@ func (h Notifications) List(w http.ResponseWriter, req *http.Request) error {
 	var opt notifications.ListOptions // TODO: Automate this conversion process.
 	if repoURI, ok := req.URL.Query()["RepoURI"]; ok {
 		if len(repoURI) != 1 {
-			return httperror.BadRequest{Err: fmt.Errorf("only one RepoURI parameter expected, but got %v", len(repoURI))}
+			return httperror.BadRequest{Err: apierror.FieldError{Field: "RepoURI", Err: fmt.Errorf("only one RepoURI parameter expected, but got %v", len(repoURI))}}
 		}
 		opt.Repo = &notifications.RepoSpec{URI: repoURI[0]}
 	}
 	opt.All, _ = strconv.ParseBool(req.URL.Query().Get("All"))
-	ns, err := h.Notifications.List(req.Context(), opt)
-	if err != nil {
+	var f filter.Options
+	f.Participating, _ = strconv.ParseBool(req.URL.Query().Get("Participating"))
+	f.Mentioned, _ = strconv.ParseBool(req.URL.Query().Get("Mentioned"))
+	f.ThreadType = req.URL.Query().Get("ThreadType")
+	f.Actor = req.URL.Query().Get("Actor")
+	f.Query = req.URL.Query().Get("Query")
+	var p pagination.Options
+	if pageSize := req.URL.Query().Get("PageSize"); pageSize != "" {
+		var err error
+		p.Size, err = strconv.Atoi(pageSize)
+		if err != nil || p.Size < 0 {
+			return httperror.BadRequest{Err: apierror.FieldError{Field: "PageSize", Err: fmt.Errorf("invalid PageSize query parameter %q", pageSize)}}
+		}
+	}
+	p.Cursor = req.URL.Query().Get("Cursor")
+	page, err := pagination.List(req.Context(), h.Notifications, opt, f, p)
+	if e, ok := err.(pagination.InvalidCursorError); ok {
+		return httperror.BadRequest{Err: apierror.FieldError{Field: "Cursor", Err: e}}
+	} else if err != nil {
 		return err
 	}
-	return httperror.JSONResponse{V: ns}
+	if page.NextCursor != "" {
+		// Link to the next page, in the style of RFC 5988.
+		next := *req.URL
+		q := next.Query()
+		q.Set("Cursor", page.NextCursor)
+		next.RawQuery = q.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
+	if validator.NotModified(w, req, validator.ETag("json", page.Notifications)) {
+		return nil
+	}
+	return httperror.JSONResponse{V: page.Notifications}
 }
 
 func (h Notifications) Count(w http.ResponseWriter, req *http.Request) error {
@@ -43,6 +115,9 @@ func (h Notifications) Count(w http.ResponseWriter, req *http.Request) error {
 	if err != nil {
 		return err
 	}
+	if validator.NotModified(w, req, validator.CountETag(n)) {
+		return nil
+	}
 	return httperror.JSONResponse{V: n}
 }
 
@@ -50,12 +125,15 @@ func (h Notifications) MarkRead(w http.ResponseWriter, req *http.Request) error
 	if req.Method != "POST" {
 		return httperror.Method{Allowed: []string{"POST"}}
 	}
+	if err := h.checkCSRF(req); err != nil {
+		return err
+	}
 	q := req.URL.Query() // TODO: Automate this conversion process.
 	repo := notifications.RepoSpec{URI: q.Get("RepoURI")}
 	threadType := q.Get("ThreadType")
 	threadID, err := strconv.ParseUint(q.Get("ThreadID"), 10, 64)
 	if err != nil {
-		return httperror.BadRequest{Err: fmt.Errorf("parsing ThreadID query parameter: %v", err)}
+		return httperror.BadRequest{Err: apierror.FieldError{Field: "ThreadID", Err: fmt.Errorf("parsing ThreadID query parameter: %v", err)}}
 	}
 	err = h.Notifications.MarkRead(req.Context(), repo, threadType, threadID)
 	return err
@@ -65,8 +143,324 @@ func (h Notifications) MarkAllRead(w http.ResponseWriter, req *http.Request) err
 	if req.Method != "POST" {
 		return httperror.Method{Allowed: []string{"POST"}}
 	}
+	if err := h.checkCSRF(req); err != nil {
+		return err
+	}
 	q := req.URL.Query() // TODO: Automate this conversion process.
 	repo := notifications.RepoSpec{URI: q.Get("RepoURI")}
 	err := h.Notifications.MarkAllRead(req.Context(), repo)
 	return err
 }
+
+// maxBatchSize is the maximum number of threads in a batch request.
+const maxBatchSize = 1000
+
+// MarkReadBatch marks many threads as read at once. The request body is
+// a JSON array of batch.Thread. It responds with a JSON array of batch.Failure,
+// describing threads that couldn't be marked as read.
+func (h Notifications) MarkReadBatch(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != "POST" {
+		return httperror.Method{Allowed: []string{"POST"}}
+	}
+	if err := h.checkCSRF(req); err != nil {
+		return err
+	}
+	var threads []batch.Thread
+	err := json.NewDecoder(req.Body).Decode(&threads)
+	if err != nil {
+		return httperror.BadRequest{Err: fmt.Errorf("decoding request body: %v", err)}
+	}
+	if len(threads) > maxBatchSize {
+		return httperror.BadRequest{Err: fmt.Errorf("too many threads in batch: %v > %v", len(threads), maxBatchSize)}
+	}
+	failures, err := batch.MarkRead(req.Context(), h.Notifications, threads)
+	if err != nil {
+		return err
+	}
+	return httperror.JSONResponse{V: failures}
+}
+
+// MarkUnread marks a thread as unread. It responds with 501 Not Implemented
+// if the notifications service doesn't support marking threads as unread.
+func (h Notifications) MarkUnread(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != "POST" {
+		return httperror.Method{Allowed: []string{"POST"}}
+	}
+	if err := h.checkCSRF(req); err != nil {
+		return err
+	}
+	m, ok := unwrap.Find[unread.Marker](h.Notifications)
+	if !ok {
+		return httperror.HTTP{Code: http.StatusNotImplemented, Err: unread.ErrNotSupported}
+	}
+	q := req.URL.Query() // TODO: Automate this conversion process.
+	repo := notifications.RepoSpec{URI: q.Get("RepoURI")}
+	threadType := q.Get("ThreadType")
+	threadID, err := strconv.ParseUint(q.Get("ThreadID"), 10, 64)
+	if err != nil {
+		return httperror.BadRequest{Err: apierror.FieldError{Field: "ThreadID", Err: fmt.Errorf("parsing ThreadID query parameter: %v", err)}}
+	}
+	err = m.MarkUnread(req.Context(), repo, threadType, threadID)
+	if err == unread.ErrNotSupported {
+		return httperror.HTTP{Code: http.StatusNotImplemented, Err: err}
+	}
+	return err
+}
+
+// UndoMarkRead undoes mark as read operations. The request body is a JSON array
+// of batch.Thread. It responds with 501 Not Implemented if the notifications service
+// doesn't implement undo.Undoer.
+func (h Notifications) UndoMarkRead(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != "POST" {
+		return httperror.Method{Allowed: []string{"POST"}}
+	}
+	if err := h.checkCSRF(req); err != nil {
+		return err
+	}
+	u, ok := unwrap.Find[undo.Undoer](h.Notifications)
+	if !ok {
+		return httperror.HTTP{Code: http.StatusNotImplemented, Err: errors.New("undoing is not supported")}
+	}
+	var threads []batch.Thread
+	err := json.NewDecoder(req.Body).Decode(&threads)
+	if err != nil {
+		return httperror.BadRequest{Err: fmt.Errorf("decoding request body: %v", err)}
+	}
+	if len(threads) > maxBatchSize {
+		return httperror.BadRequest{Err: fmt.Errorf("too many threads in batch: %v > %v", len(threads), maxBatchSize)}
+	}
+	err = u.UndoMarkRead(req.Context(), threads)
+	if err == unread.ErrNotSupported {
+		return httperror.HTTP{Code: http.StatusNotImplemented, Err: err}
+	}
+	return err
+}
+
+// Snooze snoozes a thread until the time specified in RFC 3339 format.
+// It responds with 501 Not Implemented if the notifications service
+// doesn't implement snooze.Snoozer.
+func (h Notifications) Snooze(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != "POST" {
+		return httperror.Method{Allowed: []string{"POST"}}
+	}
+	if err := h.checkCSRF(req); err != nil {
+		return err
+	}
+	s, ok := unwrap.Find[snooze.Snoozer](h.Notifications)
+	if !ok {
+		return httperror.HTTP{Code: http.StatusNotImplemented, Err: errors.New("snoozing is not supported")}
+	}
+	q := req.URL.Query() // TODO: Automate this conversion process.
+	repo := notifications.RepoSpec{URI: q.Get("RepoURI")}
+	threadType := q.Get("ThreadType")
+	threadID, err := strconv.ParseUint(q.Get("ThreadID"), 10, 64)
+	if err != nil {
+		return httperror.BadRequest{Err: apierror.FieldError{Field: "ThreadID", Err: fmt.Errorf("parsing ThreadID query parameter: %v", err)}}
+	}
+	until, err := time.Parse(time.RFC3339, q.Get("Until"))
+	if err != nil {
+		return httperror.BadRequest{Err: apierror.FieldError{Field: "Until", Err: fmt.Errorf("parsing Until query parameter: %v", err)}}
+	}
+	err = s.Snooze(req.Context(), repo, threadType, threadID, until)
+	return err
+}
+
+// Mute mutes a thread. It responds with 501 Not Implemented
+// if the notifications service doesn't implement mute.Muter.
+func (h Notifications) Mute(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != "POST" {
+		return httperror.Method{Allowed: []string{"POST"}}
+	}
+	if err := h.checkCSRF(req); err != nil {
+		return err
+	}
+	m, ok := unwrap.Find[mute.Muter](h.Notifications)
+	if !ok {
+		return httperror.HTTP{Code: http.StatusNotImplemented, Err: errors.New("muting is not supported")}
+	}
+	q := req.URL.Query() // TODO: Automate this conversion process.
+	repo := notifications.RepoSpec{URI: q.Get("RepoURI")}
+	threadType := q.Get("ThreadType")
+	threadID, err := strconv.ParseUint(q.Get("ThreadID"), 10, 64)
+	if err != nil {
+		return httperror.BadRequest{Err: apierror.FieldError{Field: "ThreadID", Err: fmt.Errorf("parsing ThreadID query parameter: %v", err)}}
+	}
+	err = m.Mute(req.Context(), repo, threadType, threadID)
+	return err
+}
+
+// Unmute unmutes a thread. It responds with 501 Not Implemented
+// if the notifications service doesn't implement mute.Muter.
+func (h Notifications) Unmute(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != "POST" {
+		return httperror.Method{Allowed: []string{"POST"}}
+	}
+	if err := h.checkCSRF(req); err != nil {
+		return err
+	}
+	m, ok := unwrap.Find[mute.Muter](h.Notifications)
+	if !ok {
+		return httperror.HTTP{Code: http.StatusNotImplemented, Err: errors.New("muting is not supported")}
+	}
+	q := req.URL.Query() // TODO: Automate this conversion process.
+	repo := notifications.RepoSpec{URI: q.Get("RepoURI")}
+	threadType := q.Get("ThreadType")
+	threadID, err := strconv.ParseUint(q.Get("ThreadID"), 10, 64)
+	if err != nil {
+		return httperror.BadRequest{Err: apierror.FieldError{Field: "ThreadID", Err: fmt.Errorf("parsing ThreadID query parameter: %v", err)}}
+	}
+	err = m.Unmute(req.Context(), repo, threadType, threadID)
+	return err
+}
+
+// Notify creates a notification in a thread. The thread is specified by
+// query parameters, and the notification request by a JSON body.
+// Only requests that AuthorizeNotify authorizes are allowed.
+func (h Notifications) Notify(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != "POST" {
+		return httperror.Method{Allowed: []string{"POST"}}
+	}
+	repo, threadType, threadID, err := h.producerThread(req)
+	if err != nil {
+		return err
+	}
+	var nr notifications.NotificationRequest
+	err = json.NewDecoder(req.Body).Decode(&nr)
+	if err != nil {
+		return httperror.BadRequest{Err: fmt.Errorf("decoding request body: %v", err)}
+	}
+	err = h.Notifications.Notify(req.Context(), repo, threadType, threadID, nr)
+	return err
+}
+
+// Subscribe subscribes users to a thread. The thread is specified by
+// query parameters, and the users by a JSON body.
+// Only requests that AuthorizeNotify authorizes are allowed,
+// since it's producers of notifications who know who's in a thread.
+func (h Notifications) Subscribe(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != "POST" {
+		return httperror.Method{Allowed: []string{"POST"}}
+	}
+	repo, threadType, threadID, err := h.producerThread(req)
+	if err != nil {
+		return err
+	}
+	var subscribers []users.UserSpec
+	err = json.NewDecoder(req.Body).Decode(&subscribers)
+	if err != nil {
+		return httperror.BadRequest{Err: fmt.Errorf("decoding request body: %v", err)}
+	}
+	err = h.Notifications.Subscribe(req.Context(), repo, threadType, threadID, subscribers)
+	return err
+}
+
+// producerThread authorizes request req made by a producer of notifications
+// with AuthorizeNotify, and returns the thread specified by its query parameters.
+func (h Notifications) producerThread(req *http.Request) (repo notifications.RepoSpec, threadType string, threadID uint64, err error) {
+	if h.AuthorizeNotify == nil {
+		return notifications.RepoSpec{}, "", 0, httperror.HTTP{Code: http.StatusForbidden, Err: errors.New("notifying is not allowed")}
+	}
+	if err := h.AuthorizeNotify(req); err != nil {
+		return notifications.RepoSpec{}, "", 0, err
+	}
+	q := req.URL.Query() // TODO: Automate this conversion process.
+	repo = notifications.RepoSpec{URI: q.Get("RepoURI")}
+	if repo.URI == "" {
+		return notifications.RepoSpec{}, "", 0, httperror.BadRequest{Err: apierror.FieldError{Field: "RepoURI", Err: errors.New("RepoURI query parameter is empty")}}
+	}
+	threadType = q.Get("ThreadType")
+	if threadType == "" {
+		return notifications.RepoSpec{}, "", 0, httperror.BadRequest{Err: apierror.FieldError{Field: "ThreadType", Err: errors.New("ThreadType query parameter is empty")}}
+	}
+	threadID, err = strconv.ParseUint(q.Get("ThreadID"), 10, 64)
+	if err != nil {
+		return notifications.RepoSpec{}, "", 0, httperror.BadRequest{Err: apierror.FieldError{Field: "ThreadID", Err: fmt.Errorf("parsing ThreadID query parameter: %v", err)}}
+	}
+	return repo, threadType, threadID, nil
+}
+
+// checkCSRF returns an error if state-changing request req
+// lacks a valid CSRF token and isn't exempt from carrying one.
+func (h Notifications) checkCSRF(req *http.Request) error {
+	skip := h.SkipCSRFCheck
+	if skip == nil {
+		skip = csrf.TokenAuthenticated
+	}
+	if skip(req) {
+		return nil
+	}
+	if !csrf.Valid(req) {
+		return httperror.HTTP{Code: http.StatusForbidden, Err: errors.New("missing or invalid CSRF token")}
+	}
+	return nil
+}
+
+// streamKeepAliveInterval is the interval at which Stream
+// sends keep-alive comments when there are no events.
+const streamKeepAliveInterval = 30 * time.Second
+
+// Stream streams events about notifications of authenticated user
+// as Server-Sent Events. The event name is the event type, and the
+// event data is the JSON encoding of the notification the event is about.
+func (h Notifications) Stream(w http.ResponseWriter, req *http.Request) error {
+	if req.Method != "GET" {
+		return httperror.Method{Allowed: []string{"GET"}}
+	}
+	if h.Events == nil {
+		return httperror.HTTP{Code: http.StatusNotFound, Err: errors.New("no source of events")}
+	}
+	flusher, ok := findFlusher(w)
+	if !ok {
+		return fmt.Errorf("streaming not supported: %T is not an http.Flusher", w)
+	}
+	events, err := h.Events.Subscribe(req.Context())
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(e.Notification)
+			if err != nil {
+				return fmt.Errorf("json.Marshal: %v", err)
+			}
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			if err != nil {
+				return err
+			}
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			if err != nil {
+				return err
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// findFlusher finds the http.Flusher of w. If w doesn't implement http.Flusher,
+// the http.ResponseWriter it wraps is tried, as reported by its Unwrap method,
+// the same way http.ResponseController does.
+func findFlusher(w http.ResponseWriter) (http.Flusher, bool) {
+	for {
+		if f, ok := w.(http.Flusher); ok {
+			return f, true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil, false
+		}
+		w = u.Unwrap()
+	}
+}
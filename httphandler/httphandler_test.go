@@ -0,0 +1,133 @@
+package httphandler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shurcooL/httperror"
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/csrf"
+	"github.com/shurcooL/notificationsapp/httphandler"
+	"github.com/shurcooL/notificationsapp/httproute"
+	"github.com/shurcooL/notificationsapp/stream"
+)
+
+func TestCSRF(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool // Whether the request is accepted.
+	}{
+		{
+			name: "no token",
+			want: false,
+		},
+		{
+			name:   "cookie without header",
+			header: http.Header{"Cookie": {csrf.Cookie + "=abc"}},
+			want:   false,
+		},
+		{
+			name:   "mismatched token",
+			header: http.Header{"Cookie": {csrf.Cookie + "=abc"}, csrf.Header: {"xyz"}},
+			want:   false,
+		},
+		{
+			name:   "valid token",
+			header: http.Header{"Cookie": {csrf.Cookie + "=abc"}, csrf.Header: {"abc"}},
+			want:   true,
+		},
+		{
+			name:   "access token",
+			header: http.Header{"Authorization": {"Bearer abc"}},
+			want:   true,
+		},
+		{
+			name:   "basic auth without token",
+			header: http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}},
+			want:   false,
+		},
+	}
+	for _, tc := range tests {
+		var marked bool
+		h := httphandler.Notifications{Notifications: markReadService{markRead: func() { marked = true }}}
+		req := httptest.NewRequest("POST", httproute.MarkRead+"?RepoURI=example.org/repo&ThreadType=Issue&ThreadID=1", nil)
+		for k, vs := range tc.header {
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+		err := h.MarkRead(httptest.NewRecorder(), req)
+		if got := err == nil; got != tc.want {
+			t.Errorf("%s: got accepted %v (error %v), want %v", tc.name, got, err, tc.want)
+		}
+		if marked != tc.want {
+			t.Errorf("%s: got marked as read %v, want %v", tc.name, marked, tc.want)
+		}
+		if e, ok := httperror.IsHTTP(err); !tc.want && (!ok || e.Code != http.StatusForbidden) {
+			t.Errorf("%s: got error %v, want 403 Forbidden", tc.name, err)
+		}
+	}
+}
+
+func TestCSRFSkip(t *testing.T) {
+	h := httphandler.Notifications{
+		Notifications: markReadService{markRead: func() {}},
+		SkipCSRFCheck: func(*http.Request) bool { return false },
+	}
+	req := httptest.NewRequest("POST", httproute.MarkRead+"?RepoURI=example.org/repo&ThreadType=Issue&ThreadID=1", nil)
+	req.Header.Set("Authorization", "Bearer abc")
+	err := h.MarkRead(httptest.NewRecorder(), req)
+	if e, ok := httperror.IsHTTP(err); !ok || e.Code != http.StatusForbidden {
+		t.Errorf("got error %v, want 403 Forbidden", err)
+	}
+}
+
+func TestStream(t *testing.T) {
+	h := httphandler.Notifications{Events: closedSource{}}
+
+	// A wrapped http.ResponseWriter is flushed via Unwrap.
+	rr := httptest.NewRecorder()
+	err := h.Stream(unwrapper{rr}, httptest.NewRequest("GET", httproute.Stream, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rr.Flushed {
+		t.Error("got not flushed, want flushed")
+	}
+
+	// An http.ResponseWriter that can't flush is an error.
+	err = h.Stream(struct{ http.ResponseWriter }{httptest.NewRecorder()}, httptest.NewRequest("GET", httproute.Stream, nil))
+	if err == nil {
+		t.Error("got nil error for an http.ResponseWriter that can't flush, want non-nil")
+	}
+}
+
+// closedSource is a stream.Source whose events channel is closed.
+type closedSource struct{}
+
+func (closedSource) Subscribe(context.Context) (<-chan stream.Event, error) {
+	ch := make(chan stream.Event)
+	close(ch)
+	return ch, nil
+}
+
+// unwrapper wraps an http.ResponseWriter without implementing http.Flusher.
+type unwrapper struct{ w http.ResponseWriter }
+
+func (u unwrapper) Header() http.Header         { return u.w.Header() }
+func (u unwrapper) Write(p []byte) (int, error) { return u.w.Write(p) }
+func (u unwrapper) WriteHeader(code int)        { u.w.WriteHeader(code) }
+func (u unwrapper) Unwrap() http.ResponseWriter { return u.w }
+
+type markReadService struct {
+	notifications.Service
+	markRead func()
+}
+
+func (s markReadService) MarkRead(context.Context, notifications.RepoSpec, string, uint64) error {
+	s.markRead()
+	return nil
+}
@@ -3,8 +3,17 @@ package httproute
 
 // Route paths.
 const (
-	List        = "/api/notifications/list"
-	Count       = "/api/notifications/count"
-	MarkRead    = "/api/notifications/mark-read"
-	MarkAllRead = "/api/notifications/mark-all-read"
+	List          = "/api/notifications/list"
+	Count         = "/api/notifications/count"
+	MarkRead      = "/api/notifications/mark-read"
+	MarkAllRead   = "/api/notifications/mark-all-read"
+	MarkReadBatch = "/api/notifications/mark-read-batch"
+	MarkUnread    = "/api/notifications/mark-unread"
+	UndoMarkRead  = "/api/notifications/undo-mark-read"
+	Snooze        = "/api/notifications/snooze"
+	Mute          = "/api/notifications/mute"
+	Unmute        = "/api/notifications/unmute"
+	Stream        = "/api/notifications/stream"
+	Notify        = "/api/notifications/notify"
+	Subscribe     = "/api/notifications/subscribe"
 )
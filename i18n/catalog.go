@@ -0,0 +1,145 @@
+package i18n
+
+// catalog contains translations of messages, by language.
+// Messages are in the default language, and are the same as ones used in code.
+// Messages without a translation in some language are displayed in the default language.
+var catalog = map[string]map[string]string{
+	"de": {
+		// Notifications.
+		"No new notifications.":             "Keine neuen Benachrichtigungen.",
+		"Mark all %s notifications as read": "Alle Benachrichtigungen von %s als gelesen markieren",
+		"Only %s notifications":             "Nur Benachrichtigungen von %s",
+		"Mark as read":                      "Als gelesen markieren",
+		"Mark as unread":                    "Als ungelesen markieren",
+		"Mute thread":                       "Thread stummschalten",
+		"Snooze":                            "Zurückstellen",
+		"Later today":                       "Später heute",
+		"Tomorrow":                          "Morgen",
+		"Next week":                         "Nächste Woche",
+		"Select":                            "Auswählen",
+		"Load more":                         "Mehr laden",
+		"Undo":                              "Rückgängig",
+		"Couldn't undo.":                    "Rückgängigmachen fehlgeschlagen.",
+		"Muted thread.":                     "Thread stummgeschaltet.",
+		"Marked %d notification as read.":   "%d Benachrichtigung als gelesen markiert.",
+		"Marked %d notifications as read.":  "%d Benachrichtigungen als gelesen markiert.",
+		"Select all":                        "Alle auswählen",
+		"Mark selected read":                "Auswahl als gelesen markieren",
+		"Failed to mark selected notifications as read.":          "Die ausgewählten Benachrichtigungen konnten nicht als gelesen markiert werden.",
+		"Failed to mark %d of %d selected notifications as read.": "%d von %d ausgewählten Benachrichtigungen konnten nicht als gelesen markiert werden.",
+
+		// Filters.
+		"Unread":        "Ungelesen",
+		"All":           "Alle",
+		"Any reason":    "Alle Gründe",
+		"Participating": "Beteiligt",
+		"Mentioned":     "Erwähnt",
+		"Any type":      "Alle Typen",
+		"Issues":        "Issues",
+		"Pull requests": "Pull-Requests",
+		"Search":        "Suchen",
+		"Actor":         "Akteur",
+
+		// Errors.
+		"Bad Request":                      "Ungültige Anfrage",
+		"Forbidden":                        "Verboten",
+		"Not Found":                        "Nicht gefunden",
+		"Method Not Allowed":               "Methode nicht erlaubt",
+		"Internal Server Error":            "Interner Serverfehler",
+		"The request couldn't be handled.": "Die Anfrage konnte nicht bearbeitet werden.",
+		"You don't have permission to view this page.":  "Sie haben keine Berechtigung, diese Seite anzuzeigen.",
+		"This page doesn't exist.":                      "Diese Seite existiert nicht.",
+		"Something went wrong. Please try again later.": "Etwas ist schiefgelaufen. Bitte versuchen Sie es später erneut.",
+
+		// Times.
+		layout:            "2.1.2006, 15:04 MST",
+		"ago":             "vor",
+		"from now":        "in",
+		"now":             "jetzt",
+		"1 second %s":     "%s 1 Sekunde",
+		"%d seconds %s":   "%s %d Sekunden",
+		"1 minute %s":     "%s 1 Minute",
+		"%d minutes %s":   "%s %d Minuten",
+		"1 hour %s":       "%s 1 Stunde",
+		"%d hours %s":     "%s %d Stunden",
+		"1 day %s":        "%s 1 Tag",
+		"%d days %s":      "%s %d Tagen",
+		"1 week %s":       "%s 1 Woche",
+		"%d weeks %s":     "%s %d Wochen",
+		"1 month %s":      "%s 1 Monat",
+		"%d months %s":    "%s %d Monaten",
+		"1 year %s":       "%s 1 Jahr",
+		"2 years %s":      "%s 2 Jahren",
+		"%d years %s":     "%s %d Jahren",
+		"a long while %s": "%s langer Zeit",
+	},
+	"fr": {
+		// Notifications.
+		"No new notifications.":             "Aucune nouvelle notification.",
+		"Mark all %s notifications as read": "Marquer toutes les notifications de %s comme lues",
+		"Only %s notifications":             "Seulement les notifications de %s",
+		"Mark as read":                      "Marquer comme lu",
+		"Mark as unread":                    "Marquer comme non lu",
+		"Mute thread":                       "Ignorer le fil",
+		"Snooze":                            "Mettre en attente",
+		"Later today":                       "Plus tard aujourd'hui",
+		"Tomorrow":                          "Demain",
+		"Next week":                         "La semaine prochaine",
+		"Select":                            "Sélectionner",
+		"Load more":                         "Charger plus",
+		"Undo":                              "Annuler",
+		"Couldn't undo.":                    "Impossible d'annuler.",
+		"Muted thread.":                     "Fil ignoré.",
+		"Marked %d notification as read.":   "%d notification marquée comme lue.",
+		"Marked %d notifications as read.":  "%d notifications marquées comme lues.",
+		"Select all":                        "Tout sélectionner",
+		"Mark selected read":                "Marquer la sélection comme lue",
+		"Failed to mark selected notifications as read.":          "Impossible de marquer les notifications sélectionnées comme lues.",
+		"Failed to mark %d of %d selected notifications as read.": "Impossible de marquer %d des %d notifications sélectionnées comme lues.",
+
+		// Filters.
+		"Unread":        "Non lues",
+		"All":           "Toutes",
+		"Any reason":    "Toutes les raisons",
+		"Participating": "Participation",
+		"Mentioned":     "Mention",
+		"Any type":      "Tous les types",
+		"Issues":        "Tickets",
+		"Pull requests": "Pull requests",
+		"Search":        "Rechercher",
+		"Actor":         "Acteur",
+
+		// Errors.
+		"Bad Request":                      "Requête invalide",
+		"Forbidden":                        "Interdit",
+		"Not Found":                        "Introuvable",
+		"Method Not Allowed":               "Méthode non autorisée",
+		"Internal Server Error":            "Erreur interne du serveur",
+		"The request couldn't be handled.": "La requête n'a pas pu être traitée.",
+		"You don't have permission to view this page.":  "Vous n'avez pas l'autorisation d'afficher cette page.",
+		"This page doesn't exist.":                      "Cette page n'existe pas.",
+		"Something went wrong. Please try again later.": "Une erreur s'est produite. Veuillez réessayer plus tard.",
+
+		// Times.
+		layout:            "02/01/2006 15:04 MST",
+		"ago":             "il y a",
+		"from now":        "dans",
+		"now":             "maintenant",
+		"1 second %s":     "%s 1 seconde",
+		"%d seconds %s":   "%s %d secondes",
+		"1 minute %s":     "%s 1 minute",
+		"%d minutes %s":   "%s %d minutes",
+		"1 hour %s":       "%s 1 heure",
+		"%d hours %s":     "%s %d heures",
+		"1 day %s":        "%s 1 jour",
+		"%d days %s":      "%s %d jours",
+		"1 week %s":       "%s 1 semaine",
+		"%d weeks %s":     "%s %d semaines",
+		"1 month %s":      "%s 1 mois",
+		"%d months %s":    "%s %d mois",
+		"1 year %s":       "%s 1 an",
+		"2 years %s":      "%s 2 ans",
+		"%d years %s":     "%s %d ans",
+		"a long while %s": "%s longtemps",
+	},
+}
@@ -0,0 +1,179 @@
+// Package i18n contains translations of the notifications app UI messages,
+// and formats times per language.
+package i18n
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Languages that the UI is available in, as BCP 47 language tags.
+// The first one is the default language, which messages are written in.
+var Languages = []string{"en", "de", "fr"}
+
+// TimeZoneCookie is the name of the cookie in which the frontend reports
+// the time zone detected by the browser, as an IANA Time Zone database name
+// (e.g., "Europe/Berlin"), for notificationsapp to display times in.
+const TimeZoneCookie = "notificationsapp-tz"
+
+// Match returns the language from Languages that best matches
+// the Accept-Language header value acceptLanguage.
+// It returns the default language if none match.
+func Match(acceptLanguage string) string {
+	best, bestQ := Languages[0], 0.0
+	for _, lr := range strings.Split(acceptLanguage, ",") {
+		tag, q := lr, 1.0
+		if i := strings.Index(lr, ";"); i != -1 {
+			tag = lr[:i]
+			param := strings.TrimSpace(lr[i+1:])
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			var err error
+			q, err = strconv.ParseFloat(param[len("q="):], 64)
+			if err != nil {
+				continue
+			}
+		}
+		lang, ok := supported(tag)
+		if !ok || q <= bestQ {
+			continue
+		}
+		best, bestQ = lang, q
+	}
+	return best
+}
+
+// supported returns the language from Languages that language tag tag
+// refers to, either exactly or as a more specific tag (e.g., "de-CH" for "de").
+func supported(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	for _, lang := range Languages {
+		if tag == lang || strings.HasPrefix(tag, lang+"-") {
+			return lang, true
+		}
+	}
+	return "", false
+}
+
+// Printer translates messages to a language, and formats times in it.
+// The zero value uses the default language, and formats absolute times
+// in their own location.
+type Printer struct {
+	lang     string
+	messages map[string]string // Message in default language -> translation.
+	loc      *time.Location    // Location of absolute times, or nil for their own location.
+}
+
+// NewPrinter returns a printer for language lang, a BCP 47 language tag.
+// If lang isn't supported, the default language is used.
+func NewPrinter(lang string) Printer {
+	lang, ok := supported(lang)
+	if !ok {
+		return Printer{}
+	}
+	return Printer{lang: lang, messages: catalog[lang]}
+}
+
+// Lang returns the language of p, as a BCP 47 language tag.
+func (p Printer) Lang() string {
+	if p.lang == "" {
+		return Languages[0]
+	}
+	return p.lang
+}
+
+// In returns a copy of p that formats absolute times in location loc.
+// If loc is nil, absolute times are formatted in their own location.
+func (p Printer) In(loc *time.Location) Printer {
+	p.loc = loc
+	return p
+}
+
+// Location returns the location that p formats absolute times in,
+// or nil if it formats them in their own location.
+func (p Printer) Location() *time.Location { return p.loc }
+
+// Text returns message msg translated to the language of p.
+// Messages without a translation are returned as is.
+func (p Printer) Text(msg string) string {
+	if t, ok := p.messages[msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// Sprintf translates format to the language of p, and formats according to it.
+func (p Printer) Sprintf(format string, a ...interface{}) string {
+	return fmt.Sprintf(p.Text(format), a...)
+}
+
+// Plural translates to the language of p and formats n according to either the
+// singular format one or the plural format other, whichever the language uses for n.
+// For example, p.Plural(n, "%d notification", "%d notifications").
+func (p Printer) Plural(n int, one, other string) string {
+	format := other
+	if p.singular(n) {
+		format = one
+	}
+	return p.Sprintf(format, n)
+}
+
+// singular reports whether the language of p uses the singular form for quantity n.
+func (p Printer) singular(n int) bool {
+	switch p.Lang() {
+	case "fr":
+		return n == 0 || n == 1
+	default:
+		return n == 1
+	}
+}
+
+// layout is the layout of absolute times, in the default language.
+const layout = "Jan 2, 2006, 3:04 PM MST"
+
+// Time formats t as an absolute time (e.g., "Jan 2, 2006, 3:04 PM MST"),
+// in the location of p if it has one.
+func (p Printer) Time(t time.Time) string {
+	if p.loc != nil {
+		t = t.In(p.loc)
+	}
+	return t.Format(p.Text(layout))
+}
+
+// RelTime formats t as a time relative to now (e.g., "2 hours ago").
+func (p Printer) RelTime(t, now time.Time) string {
+	magnitudes := make([]humanize.RelTimeMagnitude, len(relTimeMagnitudes))
+	for i, m := range relTimeMagnitudes {
+		m.Format = p.Text(m.Format)
+		magnitudes[i] = m
+	}
+	return humanize.CustomRelTime(t, now, p.Text("ago"), p.Text("from now"), magnitudes)
+}
+
+// relTimeMagnitudes are the magnitudes of relative times, in the default language.
+// They're the same as ones used by humanize.Time.
+var relTimeMagnitudes = []humanize.RelTimeMagnitude{
+	{D: time.Second, Format: "now", DivBy: time.Second},
+	{D: 2 * time.Second, Format: "1 second %s", DivBy: 1},
+	{D: time.Minute, Format: "%d seconds %s", DivBy: time.Second},
+	{D: 2 * time.Minute, Format: "1 minute %s", DivBy: 1},
+	{D: time.Hour, Format: "%d minutes %s", DivBy: time.Minute},
+	{D: 2 * time.Hour, Format: "1 hour %s", DivBy: 1},
+	{D: humanize.Day, Format: "%d hours %s", DivBy: time.Hour},
+	{D: 2 * humanize.Day, Format: "1 day %s", DivBy: 1},
+	{D: humanize.Week, Format: "%d days %s", DivBy: humanize.Day},
+	{D: 2 * humanize.Week, Format: "1 week %s", DivBy: 1},
+	{D: humanize.Month, Format: "%d weeks %s", DivBy: humanize.Week},
+	{D: 2 * humanize.Month, Format: "1 month %s", DivBy: 1},
+	{D: humanize.Year, Format: "%d months %s", DivBy: humanize.Month},
+	{D: 18 * humanize.Month, Format: "1 year %s", DivBy: 1},
+	{D: 2 * humanize.Year, Format: "2 years %s", DivBy: 1},
+	{D: humanize.LongTime, Format: "%d years %s", DivBy: humanize.Year},
+	{D: math.MaxInt64, Format: "a long while %s", DivBy: 1},
+}
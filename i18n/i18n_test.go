@@ -0,0 +1,67 @@
+package i18n_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shurcooL/notificationsapp/i18n"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "en"},
+		{"de", "de"},
+		{"de-CH, en;q=0.5", "de"},
+		{"ja, fr;q=0.8, de;q=0.7", "fr"},
+		{"en;q=0.5, fr", "fr"},
+		{"fr;q=0, de;q=0.1", "de"},
+		{"ja, *;q=0.5", "en"},
+	}
+	for _, tc := range tests {
+		if got := i18n.Match(tc.in); got != tc.want {
+			t.Errorf("Match(%q): got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestPrinter(t *testing.T) {
+	now := time.Date(2017, 2, 3, 14, 30, 0, 0, time.UTC)
+	tests := []struct {
+		lang    string
+		text    string
+		plural  string
+		relTime string
+		time    string
+	}{
+		{"", "Mark as read", "Marked 0 notifications as read.", "3 hours ago", "Feb 3, 2017, 11:30 AM UTC"},
+		{"de-DE", "Als gelesen markieren", "0 Benachrichtigungen als gelesen markiert.", "vor 3 Stunden", "3.2.2017, 11:30 UTC"},
+		{"fr", "Marquer comme lu", "0 notification marquée comme lue.", "il y a 3 heures", "03/02/2017 11:30 UTC"},
+	}
+	for _, tc := range tests {
+		p := i18n.NewPrinter(tc.lang)
+		if got := p.Text("Mark as read"); got != tc.text {
+			t.Errorf("%q: Text: got %q, want %q", tc.lang, got, tc.text)
+		}
+		if got := p.Plural(0, "Marked %d notification as read.", "Marked %d notifications as read."); got != tc.plural {
+			t.Errorf("%q: Plural: got %q, want %q", tc.lang, got, tc.plural)
+		}
+		then := now.Add(-3 * time.Hour)
+		if got := p.RelTime(then, now); got != tc.relTime {
+			t.Errorf("%q: RelTime: got %q, want %q", tc.lang, got, tc.relTime)
+		}
+		if got := p.Time(then); got != tc.time {
+			t.Errorf("%q: Time: got %q, want %q", tc.lang, got, tc.time)
+		}
+	}
+}
+
+func TestPrinterIn(t *testing.T) {
+	p := i18n.NewPrinter("en").In(time.FixedZone("PST", -8*60*60))
+	then := time.Date(2017, 2, 3, 11, 30, 0, 0, time.UTC)
+	if got, want := p.Time(then), "Feb 3, 2017, 3:30 AM PST"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
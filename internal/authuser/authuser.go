@@ -0,0 +1,28 @@
+// Package authuser contains a helper for determining the authenticated user,
+// shared by the notifications services of notificationsapp.
+package authuser
+
+import (
+	"context"
+	"os"
+
+	"github.com/shurcooL/users"
+)
+
+// Getter is the part of users.Service that's used to determine the authenticated user.
+type Getter interface {
+	GetAuthenticatedSpec(context.Context) (users.UserSpec, error)
+}
+
+// Get returns the user authenticated in ctx according to g,
+// or a permission error if there isn't one.
+func Get(ctx context.Context, g Getter) (users.UserSpec, error) {
+	user, err := g.GetAuthenticatedSpec(ctx)
+	if err != nil {
+		return users.UserSpec{}, err
+	}
+	if user.ID == 0 {
+		return users.UserSpec{}, os.ErrPermission
+	}
+	return user, nil
+}
@@ -0,0 +1,36 @@
+// Package hidden contains a helper for counting notifications that are hidden
+// from authenticated user, shared by the notifications services of notificationsapp.
+package hidden
+
+import (
+	"context"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/batch"
+)
+
+// CountUnread counts unread notifications for authenticated user
+// in threads that are hidden, according to service.
+// Only repositories that hidden threads are in are listed.
+func CountUnread(ctx context.Context, service notifications.Service, hidden map[batch.Thread]bool) (uint64, error) {
+	repos := make(map[string]bool)
+	for t := range hidden {
+		repos[t.RepoURI] = true
+	}
+	var count uint64
+	for repoURI := range repos {
+		ns, err := service.List(ctx, notifications.ListOptions{Repo: &notifications.RepoSpec{URI: repoURI}})
+		if err != nil {
+			return 0, err
+		}
+		for _, n := range ns {
+			if n.Read || n.RepoSpec.URI != repoURI {
+				continue
+			}
+			if hidden[batch.Thread{RepoURI: n.RepoSpec.URI, ThreadType: n.ThreadType, ThreadID: n.ThreadID}] {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
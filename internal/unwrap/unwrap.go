@@ -0,0 +1,35 @@
+// Package unwrap contains a helper for looking up optional interfaces
+// of notifications services that wrap other ones.
+package unwrap
+
+import "github.com/shurcooL/notifications"
+
+// Find returns the first of service and the services it wraps that implements T.
+// A service that wraps another one can make it available via an Unwrap() notifications.Service
+// method. It reports false if none of them implements T.
+//
+// A service that implements T by forwarding calls to the service it wraps
+// can report that via a Forwards(target any) bool method, which is called with
+// a nil *T. Such a service is found only if a service it wraps implements T.
+func Find[T any](service notifications.Service) (T, bool) {
+	var zero T
+	for s := service; s != nil; {
+		w, wraps := s.(interface{ Unwrap() notifications.Service })
+		if t, ok := s.(T); ok {
+			if f, ok := s.(interface{ Forwards(target any) bool }); ok && f.Forwards((*T)(nil)) {
+				if !wraps {
+					return zero, false
+				}
+				if _, ok := Find[T](w.Unwrap()); !ok {
+					return zero, false
+				}
+			}
+			return t, true
+		}
+		if !wraps {
+			break
+		}
+		s = w.Unwrap()
+	}
+	return zero, false
+}
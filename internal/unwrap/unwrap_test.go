@@ -0,0 +1,62 @@
+package unwrap_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/internal/unwrap"
+)
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name    string
+		service notifications.Service
+		want    notifications.Service // Service that's found, or nil if none.
+	}{
+		{"plain", plain{}, nil},
+		{"marker", marker{}, marker{}},
+		{"wrapped marker", wrapper{marker{}}, marker{}},
+		{"forwarder of plain", forwarder{plain{}}, nil},
+		{"forwarder of marker", forwarder{marker{}}, forwarder{marker{}}},
+		{"forwarder of forwarder of plain", forwarder{forwarder{plain{}}}, nil},
+	}
+	for _, tc := range tests {
+		m, ok := unwrap.Find[unreadMarker](tc.service)
+		if got, want := ok, tc.want != nil; got != want {
+			t.Errorf("%s: got found %v, want %v", tc.name, got, want)
+			continue
+		}
+		if ok && any(m) != any(tc.want) {
+			t.Errorf("%s: got %#v, want %#v", tc.name, m, tc.want)
+		}
+	}
+}
+
+type unreadMarker interface {
+	MarkUnread(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error
+}
+
+type plain struct{ notifications.Service }
+
+type marker struct{ notifications.Service }
+
+func (marker) MarkUnread(context.Context, notifications.RepoSpec, string, uint64) error { return nil }
+
+type wrapper struct{ notifications.Service }
+
+func (w wrapper) Unwrap() notifications.Service { return w.Service }
+
+// forwarder implements unreadMarker by forwarding to the service it wraps.
+type forwarder struct{ notifications.Service }
+
+func (f forwarder) Unwrap() notifications.Service { return f.Service }
+
+func (forwarder) MarkUnread(context.Context, notifications.RepoSpec, string, uint64) error {
+	return nil
+}
+
+func (forwarder) Forwards(target any) bool {
+	_, ok := target.(*unreadMarker)
+	return ok
+}
@@ -1,14 +1,17 @@
 package notificationsapp
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"html/template"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/shurcooL/htmlg"
 	"github.com/shurcooL/httperror"
@@ -16,6 +19,15 @@ import (
 	"github.com/shurcooL/notifications"
 	"github.com/shurcooL/notificationsapp/assets"
 	"github.com/shurcooL/notificationsapp/component"
+	"github.com/shurcooL/notificationsapp/csrf"
+	"github.com/shurcooL/notificationsapp/filter"
+	"github.com/shurcooL/notificationsapp/i18n"
+	"github.com/shurcooL/notificationsapp/internal/unwrap"
+	"github.com/shurcooL/notificationsapp/metrics"
+	"github.com/shurcooL/notificationsapp/pagination"
+	"github.com/shurcooL/notificationsapp/undo"
+	"github.com/shurcooL/notificationsapp/unread"
+	"github.com/shurcooL/notificationsapp/validator"
 	"github.com/shurcooL/users"
 )
 
@@ -24,6 +36,8 @@ import (
 //
 // In order to serve HTTP requests, the returned http.Handler expects each incoming
 // request to have a parameter provided to it via BaseURIContextKey context key.
+// The path of the request within the app is computed from req.RequestURI
+// and the base URI, so there's no need to strip the base URI from req.URL.Path.
 // For example:
 //
 // 	notificationsApp := notificationsapp.New(...)
@@ -36,18 +50,73 @@ import (
 // An HTTP API must be available:
 //
 // 	// Register HTTP API endpoints.
-// 	apiHandler := httphandler.Notifications{Notifications: service}
+// 	events := stream.NewHub(users)
+// 	service = stream.NewService(service, events)
+// 	apiHandler := httphandler.Notifications{Notifications: service, Events: events}
+// 	http.Handle(httproute.List, errorHandler(apiHandler.List))
 // 	http.Handle(httproute.MarkRead, errorHandler(apiHandler.MarkRead))
 // 	http.Handle(httproute.MarkAllRead, errorHandler(apiHandler.MarkAllRead))
+// 	http.Handle(httproute.MarkReadBatch, errorHandler(apiHandler.MarkReadBatch))
+// 	http.Handle(httproute.MarkUnread, errorHandler(apiHandler.MarkUnread))
+// 	http.Handle(httproute.UndoMarkRead, errorHandler(apiHandler.UndoMarkRead))
+// 	http.Handle(httproute.Snooze, errorHandler(apiHandler.Snooze))
+// 	http.Handle(httproute.Mute, errorHandler(apiHandler.Mute))
+// 	http.Handle(httproute.Unmute, errorHandler(apiHandler.Unmute))
+// 	http.Handle(httproute.Stream, errorHandler(apiHandler.Stream))
+// 	http.Handle(httproute.Notify, errorHandler(apiHandler.Notify))
+// 	http.Handle(httproute.Subscribe, errorHandler(apiHandler.Subscribe))
+//
+// State-changing API requests made by the frontend carry the CSRF token of the page,
+// which the app sets in the csrf.Cookie cookie. Requests made by other API clients that
+// authenticate with a bearer access token don't need to carry one. See apiHandler.SkipCSRFCheck.
+//
+// The errorHandler used above can be APIHandler, which handles errors the same way
+// the app does, and logs requests to opt.Logger.
+//
+// Mount can be used to do all of the above.
 func New(service notifications.Service, users users.Service, opt Options) http.Handler {
 	h := handler{
 		ns:               service,
 		assetsFileServer: httpgzip.FileServer(assets.Assets, httpgzip.FileServerOptions{ServeError: httpgzip.Detailed}),
 		opt:              opt,
 	}
-	return &errorHandler{
-		handler: h.ServeHTTP,
-		users:   users,
+	var app http.Handler = &errorHandler{
+		handler:   h.ServeHTTP,
+		users:     users,
+		logger:    opt.Logger,
+		onError:   opt.OnError,
+		errorPage: h.renderErrorPage,
+	}
+	if opt.Metrics != nil {
+		app = instrument(opt.Metrics, app)
+	}
+	return app
+}
+
+// instrument returns app with requests to it recorded in m, by app route.
+func instrument(m *metrics.Metrics, app http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		m.Handler(appRoute(req), app).ServeHTTP(w, req)
+	})
+}
+
+// appRoute returns the app route that req is made to, for use as a metrics label.
+// It's "/" for the notifications page, "/repo/" for repository notifications pages,
+// "/assets/" for assets, "/feed.atom" and "/feed.json" for feeds, or "other".
+func appRoute(req *http.Request) string {
+	baseURI, ok := req.Context().Value(BaseURIContextKey).(string)
+	if !ok {
+		return "other"
+	}
+	switch path := appPath(req, baseURI); {
+	case path == "/", path == "/feed.atom", path == "/feed.json":
+		return path
+	case strings.HasPrefix(path, "/repo/"):
+		return "/repo/"
+	case strings.HasPrefix(path, "/assets/"):
+		return "/assets/"
+	default:
+		return "other"
 	}
 }
 
@@ -62,12 +131,11 @@ type handler struct {
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) error {
-	// TODO: Caller still does a lot of work outside to calculate req.URL.Path by
-	//       subtracting BaseURI from full original req.URL.Path. We should be able
-	//       to compute it here internally by using req.RequestURI and BaseURI.
-	if _, ok := req.Context().Value(BaseURIContextKey).(string); !ok {
+	baseURI, ok := req.Context().Value(BaseURIContextKey).(string)
+	if !ok {
 		return fmt.Errorf("request to %v doesn't have notificationsapp.BaseURIContextKey context key set", req.URL.Path)
 	}
+	req = withPath(req, appPath(req, baseURI))
 
 	// Handle "/assets/...".
 	if strings.HasPrefix(req.URL.Path, "/assets/") {
@@ -76,6 +144,23 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) error {
 		return nil
 	}
 
+	// Handle "/feed.atom" and "/feed.json".
+	switch req.URL.Path {
+	case "/feed.atom":
+		return h.FeedHandler(w, req, "atom")
+	case "/feed.json":
+		return h.FeedHandler(w, req, "json")
+	}
+
+	// Handle "/repo/...".
+	if strings.HasPrefix(req.URL.Path, "/repo/") {
+		repoURI := req.URL.Path[len("/repo/"):]
+		if repoURI == "" {
+			return httperror.HTTP{Code: http.StatusNotFound, Err: errors.New("no repo URI")}
+		}
+		return h.RepoNotificationsHandler(w, req, notifications.RepoSpec{URI: repoURI})
+	}
+
 	// Handle all other non-"/".
 	if req.URL.Path != "/" {
 		return httperror.HTTP{Code: http.StatusNotFound, Err: errors.New("no route")}
@@ -92,6 +177,79 @@ type Options struct {
 
 	// BodyTop provides components to include on top of <body> of page rendered for req. It can be nil.
 	BodyTop func(req *http.Request) ([]htmlg.Component, error)
+
+	// APIBaseURI is the base URI prefix of the HTTP API endpoints, which the
+	// frontend makes requests to at APIBaseURI + httproute paths. It's empty if
+	// the endpoints are at httproute paths as is. Mount sets it.
+	APIBaseURI string
+
+	// PageSize is the maximum number of notifications to display at once.
+	// More notifications can be loaded by the user one page at a time.
+	// Zero means no limit.
+	PageSize int
+
+	// Locale, if not nil, returns the language to display the UI in for req,
+	// as a BCP 47 language tag (e.g., a language the user chose in their settings).
+	// If it's nil or returns "", the language is chosen based on the Accept-Language
+	// header of req. See i18n.Languages for supported languages.
+	Locale func(req *http.Request) string
+
+	// Location, if not nil, returns the time zone to display absolute times in for req
+	// (e.g., a time zone the user chose in their settings). If it's nil or returns nil,
+	// the time zone detected by the browser is used, which the frontend reports in the
+	// i18n.TimeZoneCookie cookie. If there's none, times are displayed in the time zone
+	// that the notifications service returned them in.
+	Location func(req *http.Request) *time.Location
+
+	// ContentSecurityPolicy, if not nil, returns the value of the Content-Security-Policy
+	// header to serve the page for req with. nonce is generated for each page, and the
+	// page's script and stylesheet tags are marked with it, so the policy can allow them
+	// via a 'nonce-<nonce>' source expression. HeadPre and BodyPre aren't marked with it.
+	// DefaultContentSecurityPolicy can be used.
+	ContentSecurityPolicy func(req *http.Request, nonce string) string
+
+	// Logger, if not nil, is used to log each request to the app and, if they're
+	// registered via Mount or APIHandler, to its HTTP API endpoints. If it's nil,
+	// only errors are logged, via the standard log package.
+	Logger Logger
+
+	// OnError, if not nil, is called with each failure that occurs while serving a request
+	// to the app and, if they're registered via Mount or APIHandler, to its HTTP API endpoints
+	// (e.g., to forward it to an error tracker). Failures are errors that result in a 5xx
+	// status code, errors that occur after the response header was written, and panics,
+	// which are recovered and handled as errors. stack is the stack trace of a panic,
+	// or nil if err isn't one. OnError is called after the response is written.
+	OnError func(req *http.Request, err error, stack []byte)
+
+	// Metrics, if not nil, records metrics about requests to the app, by app route
+	// (e.g., "/repo/"). Mount also records metrics about requests to HTTP API endpoints,
+	// by httproute path, and about calls to the notifications service via metrics.NewService.
+	// Metrics can be served by registering it as an http.Handler at a path of choice.
+	Metrics *metrics.Metrics
+}
+
+// Logger logs structured records. A *slog.Logger can be used.
+//
+// Each request is logged as a record with the message "request" and attributes
+// method, path, status (status code), latency (time.Duration), and if authenticated,
+// user (user spec, e.g., "1@example.com"). If handling the request failed,
+// there are also attributes error and error_class, which is one of:
+//
+// 	"method"       request method is not allowed
+// 	"bad_request"  httperror.BadRequest
+// 	"http"         httperror.HTTP, with its own status code
+// 	"not_exist"    os.IsNotExist or errors.Is(err, os.ErrNotExist) reports true
+// 	"permission"   os.IsPermission or errors.Is(err, os.ErrPermission) reports true
+// 	"internal"     any other error
+// 	"after_header" error after the response header was written
+//
+// Panics are handled as errors, and their records also have the attribute stack.
+//
+// Records with status 5xx and ones with error class "after_header" are logged at error level,
+// others are logged at info level.
+type Logger interface {
+	InfoContext(ctx context.Context, msg string, args ...interface{})
+	ErrorContext(ctx context.Context, msg string, args ...interface{})
 }
 
 // BaseURIContextKey is a context key for the request's base URI.
@@ -109,39 +267,341 @@ func (k *contextKey) String() string {
 	return "github.com/shurcooL/notificationsapp context value " + k.name
 }
 
-var notificationsHTML = template.Must(template.New("").Parse(`<html>
+// undoSupported reports whether service supports undoing mark as read operations,
+// so the frontend offers to undo them. Undoing marks threads as unread,
+// so service must support that too.
+func undoSupported(service notifications.Service) bool {
+	if _, ok := unwrap.Find[undo.Undoer](service); !ok {
+		return false
+	}
+	_, ok := unwrap.Find[unread.Marker](service)
+	return ok
+}
+
+var notificationsHTML = template.Must(template.New("").Parse(`<html lang="{{.Lang}}">
 	<head>
 		{{.HeadPre}}
-		<link href="{{.BaseURI}}/assets/style.css" rel="stylesheet" type="text/css" />
-		<script src="{{.BaseURI}}/assets/script.js" type="text/javascript"></script>
+		<meta name="notificationsapp-base-uri" content="{{.BaseURI}}" />
+		<meta name="notificationsapp-api-base-uri" content="{{.APIBaseURI}}" />
+		<meta name="notificationsapp-time-zone" content="{{.TimeZone}}" />
+		<meta name="notificationsapp-csrf-token" content="{{.CSRFToken}}" />
+		{{if .Undo}}<meta name="notificationsapp-undo" content="supported" />{{end}}
+		<link href="{{.BaseURI}}/assets/style.css" rel="stylesheet" type="text/css"{{with .Nonce}} nonce="{{.}}"{{end}} />
+		<link href="{{.BaseURI}}/feed.atom" rel="alternate" type="application/atom+xml" title="Notifications" />
+		<link href="{{.BaseURI}}/feed.json" rel="alternate" type="application/feed+json" title="Notifications" />
+		{{if .Script}}<script src="{{.BaseURI}}/assets/script.js" type="text/javascript"{{with .Nonce}} nonce="{{.}}"{{end}}></script>{{end}}
 	</head>
 	<body>
 		{{.BodyPre}}`))
 
 func (h *handler) NotificationsHandler(w http.ResponseWriter, req *http.Request) error {
+	if req.Method == "POST" {
+		return h.action(req)
+	}
 	if req.Method != "GET" {
-		return httperror.Method{Allowed: []string{"GET"}}
+		return httperror.Method{Allowed: []string{"GET", "POST"}}
 	}
 
-	all, _ := strconv.ParseBool(req.URL.Query().Get("all"))
-	ns, err := h.ns.List(req.Context(), notifications.ListOptions{
+	all, f := component.ParseQuery(req.URL.Query())
+	page, err := h.listPage(req, notifications.ListOptions{
 		All: all,
-	})
+	}, f)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if acceptsJSON(req) {
+		return jsonPage(w, req, page)
+	}
+	p := h.printer(req)
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Add("Vary", "Cookie")
+	token, err := csrf.Token(w, req)
+	if err != nil {
+		return fmt.Errorf("csrf.Token: %v", err)
+	}
+	if validator.NotModified(w, req, htmlETag(p, token, page.Notifications)) {
+		return nil
+	}
+	return h.renderPage(w, req, http.StatusOK, p, token,
+		component.Filters{All: all, Filter: f, Printer: p},
+		component.Toolbar{Printer: p},
+		component.NotificationsByRepo{Notifications: page.Notifications, BaseURI: req.Context().Value(BaseURIContextKey).(string), Printer: p, CSRFToken: token},
+		component.LoadMore{All: all, Filter: f, PageSize: h.opt.PageSize, Cursor: page.NextCursor, Printer: p},
+	)
+}
+
+// RepoNotificationsHandler serves notifications from the single repository repo.
+func (h *handler) RepoNotificationsHandler(w http.ResponseWriter, req *http.Request, repo notifications.RepoSpec) error {
+	if req.Method == "POST" {
+		return h.action(req)
+	}
+	if req.Method != "GET" {
+		return httperror.Method{Allowed: []string{"GET", "POST"}}
+	}
+
+	all, f := component.ParseQuery(req.URL.Query())
+	page, err := h.listPage(req, notifications.ListOptions{
+		Repo: &repo,
+		All:  all,
+	}, f)
 	if err != nil {
 		return err
 	}
 
+	w.Header().Add("Vary", "Accept")
+	if acceptsJSON(req) {
+		return jsonPage(w, req, page)
+	}
+	p := h.printer(req)
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Add("Vary", "Cookie")
+	token, err := csrf.Token(w, req)
+	if err != nil {
+		return fmt.Errorf("csrf.Token: %v", err)
+	}
+	if validator.NotModified(w, req, htmlETag(p, token, page.Notifications)) {
+		return nil
+	}
+
+	rn := component.RepoNotifications{Repo: repo, BaseURI: req.Context().Value(BaseURIContextKey).(string), Printer: p, CSRFToken: token}
+	for _, n := range page.Notifications {
+		rn.Notifications = append(rn.Notifications, component.Notification{Notification: n, Printer: p, CSRFToken: token})
+	}
+	return h.renderPage(w, req, http.StatusOK, p, token,
+		component.Filters{All: all, Filter: f, Printer: p},
+		component.Toolbar{Printer: p},
+		rn,
+		component.LoadMore{Repo: &repo, All: all, Filter: f, PageSize: h.opt.PageSize, Cursor: page.NextCursor, Printer: p},
+	)
+}
+
+// action performs an action submitted via a form on a notifications page,
+// and redirects back to the page, scrolled to the repository acted on.
+// It makes the actions work without the frontend script.
+func (h *handler) action(req *http.Request) error {
+	err := req.ParseForm()
+	if err != nil {
+		return httperror.BadRequest{Err: err}
+	}
+	if !csrf.Valid(req) {
+		return httperror.HTTP{Code: http.StatusForbidden, Err: errors.New("missing or invalid CSRF token")}
+	}
+	repo := notifications.RepoSpec{URI: req.PostForm.Get("repo")}
+	if repo.URI == "" {
+		return httperror.BadRequest{Err: errors.New("no repo URI")}
+	}
+	switch op := req.PostForm.Get("op"); op {
+	case "mark-read":
+		threadID, err := strconv.ParseUint(req.PostForm.Get("id"), 10, 64)
+		if err != nil {
+			return httperror.BadRequest{Err: fmt.Errorf("parsing id: %v", err)}
+		}
+		err = h.ns.MarkRead(req.Context(), repo, req.PostForm.Get("type"), threadID)
+		if err != nil {
+			return err
+		}
+	case "mark-all-read":
+		err := h.ns.MarkAllRead(req.Context(), repo)
+		if err != nil {
+			return err
+		}
+	default:
+		return httperror.BadRequest{Err: fmt.Errorf("unsupported op %q", op)}
+	}
+	return httperror.Redirect{URL: pageURL(req, component.RepoAnchor(repo.URI))}
+}
+
+// pageURL returns the URL of the page that req was made to,
+// including its query, with the specified fragment.
+func pageURL(req *http.Request, fragment string) string {
+	u := requestURI(req)
+	u.Fragment = fragment
+	return u.String()
+}
+
+// requestURI returns the request URI of req, including the base URI
+// that req.URL.Path no longer has.
+func requestURI(req *http.Request) *url.URL {
+	u, err := url.ParseRequestURI(req.RequestURI)
+	if err != nil {
+		baseURI := req.Context().Value(BaseURIContextKey).(string)
+		u = &url.URL{Path: strings.TrimSuffix(baseURI, "/") + req.URL.Path, RawQuery: req.URL.RawQuery}
+	}
+	return u
+}
+
+// listPage lists the page of notifications matching opt and f
+// that is specified by the "cursor" query parameter of req.
+func (h *handler) listPage(req *http.Request, opt notifications.ListOptions, f filter.Options) (pagination.Page, error) {
+	page, err := pagination.List(req.Context(), h.ns, opt, f, pagination.Options{
+		Size:   h.opt.PageSize,
+		Cursor: req.URL.Query().Get("cursor"),
+	})
+	if e, ok := err.(pagination.InvalidCursorError); ok {
+		return pagination.Page{}, httperror.BadRequest{Err: e}
+	}
+	return page, err
+}
+
+// printer returns a printer for the language and time zone to display the UI in for req.
+// See Options.Locale and Options.Location.
+func (h *handler) printer(req *http.Request) i18n.Printer {
+	var lang string
+	if h.opt.Locale != nil {
+		lang = h.opt.Locale(req)
+	}
+	if lang == "" {
+		lang = i18n.Match(req.Header.Get("Accept-Language"))
+	}
+	return i18n.NewPrinter(lang).In(h.location(req))
+}
+
+// location returns the time zone to display absolute times in for req,
+// or nil if they should be displayed in their own time zone.
+func (h *handler) location(req *http.Request) *time.Location {
+	if h.opt.Location != nil {
+		if loc := h.opt.Location(req); loc != nil {
+			return loc
+		}
+	}
+	cookie, err := req.Cookie(i18n.TimeZoneCookie)
+	if err != nil {
+		return nil
+	}
+	loc, err := time.LoadLocation(cookie.Value)
+	if err != nil || cookie.Value == "" {
+		// Not a valid time zone, or "" which would mean UTC.
+		return nil
+	}
+	return loc
+}
+
+// htmlETag returns the ETag of an HTML page that displays notifications ns
+// in the language and time zone of printer p, with CSRF token csrfToken.
+// It's weak, since the page displays times relative to when it was rendered.
+func htmlETag(p i18n.Printer, csrfToken string, ns notifications.Notifications) string {
+	kind := "html." + p.Lang()
+	if loc := p.Location(); loc != nil {
+		kind += "." + loc.String()
+	}
+	kind += "." + csrfToken
+	return validator.WeakETag(kind, ns)
+}
+
+// acceptsJSON reports whether req prefers a JSON response to an HTML one.
+// The "format" query parameter, if set to "json" or "html", takes precedence
+// over the Accept header.
+func acceptsJSON(req *http.Request) bool {
+	switch req.URL.Query().Get("format") {
+	case "json":
+		return true
+	case "html":
+		return false
+	}
+	jsonQ, htmlQ := acceptQ(req, "application/json"), acceptQ(req, "text/html")
+	return jsonQ > htmlQ
+}
+
+// acceptsHTML reports whether req accepts an HTML response explicitly,
+// as browsers do. The "format" query parameter, if set to "html",
+// takes precedence over the Accept header.
+func acceptsHTML(req *http.Request) bool {
+	if req.URL.Query().Get("format") == "html" {
+		return true
+	}
+	return acceptQ(req, "text/html") > 0
+}
+
+// acceptQ returns the quality value that the Accept header of req
+// gives to media type mediaType, or 0 if it's not listed.
+func acceptQ(req *http.Request, mediaType string) float64 {
+	var q float64
+	for _, mediaRange := range strings.Split(req.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil || mt != mediaType {
+			continue
+		}
+		q = 1.0
+		if v, ok := params["q"]; ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+	}
+	return q
+}
+
+// jsonPage responds to req with notifications in page, encoded as JSON
+// the same way as httphandler.List does. If there's a next page,
+// it's linked to from the Link header. Conditional requests are supported.
+func jsonPage(w http.ResponseWriter, req *http.Request, page pagination.Page) error {
+	if page.NextCursor != "" {
+		next := requestURI(req)
+		q := next.Query()
+		q.Set("cursor", page.NextCursor)
+		next.RawQuery = q.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
+	if validator.NotModified(w, req, validator.ETag("json", page.Notifications)) {
+		return nil
+	}
+	return httperror.JSONResponse{V: page.Notifications}
+}
+
+// renderErrorPage renders a complete page for req that displays an error
+// with status code code, and details, if not empty. It's used by errorHandler.
+func (h *handler) renderErrorPage(w http.ResponseWriter, req *http.Request, code int, details string) error {
+	if _, ok := req.Context().Value(BaseURIContextKey).(string); !ok {
+		return fmt.Errorf("request to %v doesn't have notificationsapp.BaseURIContextKey context key set", req.URL.Path)
+	}
+	p := h.printer(req)
+	return h.renderPage(w, req, code, p, "", component.Error{Code: code, Details: details, Printer: p})
+}
+
+// renderPage renders a complete notifications page for req with status code code
+// in the language of printer p, with CSRF token csrfToken and components c as its contents.
+// Pages with a status code other than 200 OK are error pages, which don't include
+// the frontend script, since there are no notifications for it to act on.
+func (h *handler) renderPage(w http.ResponseWriter, req *http.Request, code int, p i18n.Printer, csrfToken string, c ...htmlg.Component) error {
+	var nonce string
+	if h.opt.ContentSecurityPolicy != nil {
+		var err error
+		nonce, err = newNonce()
+		if err != nil {
+			return fmt.Errorf("newNonce: %v", err)
+		}
+		w.Header().Set("Content-Security-Policy", h.opt.ContentSecurityPolicy(req, nonce))
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	var timeZone string
+	if loc := p.Location(); loc != nil {
+		timeZone = loc.String()
+	}
 	state := struct {
-		BaseURI string
-		HeadPre template.HTML
-		BodyPre template.HTML // E.g., <div style="max-width: 800px; margin: 0 auto 100px auto;">.
+		Lang       string // Language of the page. The frontend displays its messages in it.
+		TimeZone   string // Time zone of absolute times on the page, or empty if they're in their own.
+		Nonce      string // Nonce of the Content-Security-Policy, if any.
+		CSRFToken  string // CSRF token that the frontend sends with state-changing requests.
+		Script     bool   // Whether to include the frontend script.
+		Undo       bool   // Whether the notifications service supports undoing mark as read operations.
+		BaseURI    string
+		APIBaseURI string
+		HeadPre    template.HTML
+		BodyPre    template.HTML // E.g., <div style="max-width: 800px; margin: 0 auto 100px auto;">.
 	}{
+		p.Lang(),
+		timeZone,
+		nonce,
+		csrfToken,
+		code == http.StatusOK,
+		undoSupported(h.ns),
 		req.Context().Value(BaseURIContextKey).(string),
+		h.opt.APIBaseURI,
 		h.opt.HeadPre,
 		h.opt.BodyPre,
 	}
-	err = notificationsHTML.Execute(w, &state)
+	err := notificationsHTML.Execute(w, &state)
 	if err != nil {
 		return fmt.Errorf("notificationsHTML.Execute: %v", err)
 	}
@@ -159,7 +619,7 @@ func (h *handler) NotificationsHandler(w http.ResponseWriter, req *http.Request)
 	}
 
 	// Render the notifications contents.
-	err = htmlg.RenderComponents(w, component.NotificationsByRepo{Notifications: ns})
+	err = htmlg.RenderComponents(w, c...)
 	if err != nil {
 		return fmt.Errorf("htmlg.RenderComponents: %v", err)
 	}
@@ -171,6 +631,45 @@ func (h *handler) NotificationsHandler(w http.ResponseWriter, req *http.Request)
 	return nil
 }
 
+// appPath returns the path of req relative to the base URI baseURI of the app.
+// It's computed from the original request URI, so it doesn't matter whether
+// the caller has already stripped baseURI from req.URL.Path or not.
+// If baseURI is not an absolute path that prefixes the request URI path
+// (e.g., it's a relative URI like "."), req.URL.Path is used as is.
+func appPath(req *http.Request, baseURI string) string {
+	baseURI = strings.TrimSuffix(baseURI, "/")
+	if baseURI != "" && !strings.HasPrefix(baseURI, "/") {
+		return req.URL.Path
+	}
+	u, err := url.ParseRequestURI(req.RequestURI)
+	if err != nil || !strings.HasPrefix(u.Path, baseURI) {
+		return req.URL.Path
+	}
+	switch p := u.Path[len(baseURI):]; {
+	case p == "":
+		return "/"
+	case p[0] != '/':
+		// The base URI is not a prefix at a path segment boundary.
+		return req.URL.Path
+	default:
+		return p
+	}
+}
+
+// withPath returns request r with r.URL.Path changed to path.
+func withPath(r *http.Request, path string) *http.Request {
+	if r.URL.Path == path {
+		return r
+	}
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = path
+	r2.URL.RawPath = ""
+	return r2
+}
+
 // stripPrefix returns request r with prefix of length prefixLen stripped from r.URL.Path.
 // prefixLen must not be longer than len(r.URL.Path), otherwise stripPrefix panics.
 // If r.URL.Path is empty after the prefix is stripped, the path is changed to "/".
@@ -0,0 +1,90 @@
+package notificationsapp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/stream"
+	"github.com/shurcooL/notificationsapp/undo"
+)
+
+func TestAppPath(t *testing.T) {
+	tests := []struct {
+		requestURI string
+		urlPath    string // req.URL.Path as provided by the caller.
+		baseURI    string
+		want       string
+	}{
+		{"/notifications/repo/github.com/a/b?all=1", "/notifications/repo/github.com/a/b", "/notifications", "/repo/github.com/a/b"},
+		{"/notifications/repo/github.com/a/b?all=1", "/repo/github.com/a/b", "/notifications", "/repo/github.com/a/b"},
+		{"/notifications", "/notifications", "/notifications", "/"},
+		{"/notifications/", "/notifications/", "/notifications/", "/"},
+		{"/assets/style.css", "/assets/style.css", "", "/assets/style.css"},
+		{"/notificationsfoo", "/notificationsfoo", "/notifications", "/notificationsfoo"},
+		{"/feed.atom", "/feed.atom", ".", "/feed.atom"},
+		{"/other/feed.atom", "/feed.atom", "/notifications", "/feed.atom"},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest("GET", tc.requestURI, nil)
+		req.URL.Path = tc.urlPath
+		if got := appPath(req, tc.baseURI); got != tc.want {
+			t.Errorf("appPath(%q, %q): got %q, want %q", tc.requestURI, tc.baseURI, got, tc.want)
+		}
+	}
+}
+
+func TestUndoMeta(t *testing.T) {
+	const meta = `<meta name="notificationsapp-undo"`
+	for _, tc := range []struct {
+		service notifications.Service
+		want    bool
+	}{
+		{listService{}, false},
+		{undo.NewService(listService{}, nil), false}, // Nothing marks threads as unread.
+		{undo.NewService(unreadService{}, nil), true},
+		{undo.NewService(stream.NewService(listService{}, stream.NewHub(nil)), nil), false}, // stream.Service only forwards MarkUnread.
+		{undo.NewService(stream.NewService(unreadService{}, stream.NewHub(nil)), nil), true},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), BaseURIContextKey, ""))
+		rr := httptest.NewRecorder()
+		New(tc.service, nil, Options{}).ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("got status %v, want %v:\n%s", rr.Code, http.StatusOK, rr.Body)
+		}
+		if got := strings.Contains(rr.Body.String(), meta); got != tc.want {
+			t.Errorf("%T: got undo meta %v, want %v", tc.service, got, tc.want)
+		}
+	}
+}
+
+type unreadService struct {
+	listService
+}
+
+func (unreadService) MarkUnread(context.Context, notifications.RepoSpec, string, uint64) error {
+	return nil
+}
+
+func TestJSONPageNextLink(t *testing.T) {
+	service := listService{ns: notifications.Notifications{
+		{RepoSpec: notifications.RepoSpec{URI: "example.org/repo"}, ThreadType: "Issue", ThreadID: 1},
+		{RepoSpec: notifications.RepoSpec{URI: "example.org/repo"}, ThreadType: "Issue", ThreadID: 2},
+	}}
+	req := httptest.NewRequest("GET", "/notifications/?format=json", nil)
+	req.URL.Path = "/"
+	req = req.WithContext(context.WithValue(req.Context(), BaseURIContextKey, "/notifications"))
+	rr := httptest.NewRecorder()
+	New(service, nil, Options{PageSize: 1}).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %v, want %v:\n%s", rr.Code, http.StatusOK, rr.Body)
+	}
+	link := rr.Header().Get("Link")
+	if !strings.HasPrefix(link, "</notifications/?") || !strings.Contains(link, "cursor=") || !strings.HasSuffix(link, `>; rel="next"`) {
+		t.Errorf("got Link %q, want a next link under base URI /notifications", link)
+	}
+}
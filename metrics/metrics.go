@@ -0,0 +1,365 @@
+// Package metrics contains instrumentation of the notifications app, its HTTP API
+// and notifications service, with metrics exposed in the Prometheus text exposition format.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/batch"
+	"github.com/shurcooL/notificationsapp/filter"
+	"github.com/shurcooL/notificationsapp/internal/unwrap"
+	"github.com/shurcooL/notificationsapp/pagination"
+	"github.com/shurcooL/notificationsapp/unread"
+)
+
+// Metrics collects metrics about HTTP requests and notifications service calls.
+// It's an http.Handler that serves them in the Prometheus text exposition format,
+// so it can be registered at a path of choice. For example:
+//
+// 	m := metrics.New()
+// 	http.Handle("/metrics", m)
+//
+// The metrics are:
+//
+// 	notificationsapp_http_requests_total{route, code}                counter
+// 	notificationsapp_http_request_duration_seconds{route}            histogram
+// 	notificationsapp_backend_call_duration_seconds{method, result}   histogram
+//
+// Where result is "success" or "error". Durations of streaming responses
+// (Server-Sent Events, such as httproute.Stream) aren't recorded, since they
+// last as long as the client stays connected.
+type Metrics struct {
+	mu              sync.Mutex
+	requests        map[requestKey]uint64     // Number of requests.
+	requestDuration map[string]*histogram     // Request durations by route.
+	backendDuration map[backendKey]*histogram // Service call durations.
+}
+
+type requestKey struct {
+	Route string
+	Code  int
+}
+
+type backendKey struct {
+	Method string
+	Result string // "success" or "error".
+}
+
+// New creates an empty collection of metrics.
+func New() *Metrics {
+	return &Metrics{
+		requests:        make(map[requestKey]uint64),
+		requestDuration: make(map[string]*histogram),
+		backendDuration: make(map[backendKey]*histogram),
+	}
+}
+
+// Handler returns an http.Handler that serves requests with h,
+// and records them under the route label route (e.g., httproute.List).
+func (m *Metrics) Handler(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		h.ServeHTTP(rw, req)
+		streaming := rw.Header().Get("Content-Type") == "text/event-stream"
+		m.observeRequest(route, rw.StatusCode(), time.Since(start), !streaming)
+	})
+}
+
+// observeRequest records a request to route that resulted in status code,
+// and its duration d if recordDuration is true.
+func (m *Metrics) observeRequest(route string, code int, d time.Duration, recordDuration bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{Route: route, Code: code}]++
+	if !recordDuration {
+		return
+	}
+	h, ok := m.requestDuration[route]
+	if !ok {
+		h = newHistogram()
+		m.requestDuration[route] = h
+	}
+	h.Observe(d.Seconds())
+}
+
+func (m *Metrics) observeBackend(method string, d time.Duration, err error) {
+	k := backendKey{Method: method, Result: "success"}
+	if err != nil {
+		k.Result = "error"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.backendDuration[k]
+	if !ok {
+		h = newHistogram()
+		m.backendDuration[k] = h
+	}
+	h.Observe(d.Seconds())
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if req.Method == "HEAD" {
+		return
+	}
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ew := &errWriter{w: w}
+
+	fmt.Fprintln(ew, "# HELP notificationsapp_http_requests_total Number of HTTP requests, by route and status code.")
+	fmt.Fprintln(ew, "# TYPE notificationsapp_http_requests_total counter")
+	var rks []requestKey
+	for k := range m.requests {
+		rks = append(rks, k)
+	}
+	sort.Slice(rks, func(i, j int) bool {
+		if rks[i].Route != rks[j].Route {
+			return rks[i].Route < rks[j].Route
+		}
+		return rks[i].Code < rks[j].Code
+	})
+	for _, k := range rks {
+		fmt.Fprintf(ew, "notificationsapp_http_requests_total{route=%s,code=\"%d\"} %d\n", quote(k.Route), k.Code, m.requests[k])
+	}
+
+	fmt.Fprintln(ew, "# HELP notificationsapp_http_request_duration_seconds Duration of HTTP requests, by route.")
+	fmt.Fprintln(ew, "# TYPE notificationsapp_http_request_duration_seconds histogram")
+	var routes []string
+	for route := range m.requestDuration {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		m.requestDuration[route].write(ew, "notificationsapp_http_request_duration_seconds", "route="+quote(route))
+	}
+
+	fmt.Fprintln(ew, "# HELP notificationsapp_backend_call_duration_seconds Duration of notifications service calls, by method and result.")
+	fmt.Fprintln(ew, "# TYPE notificationsapp_backend_call_duration_seconds histogram")
+	var bks []backendKey
+	for k := range m.backendDuration {
+		bks = append(bks, k)
+	}
+	sort.Slice(bks, func(i, j int) bool {
+		if bks[i].Method != bks[j].Method {
+			return bks[i].Method < bks[j].Method
+		}
+		return bks[i].Result < bks[j].Result
+	})
+	for _, k := range bks {
+		m.backendDuration[k].write(ew, "notificationsapp_backend_call_duration_seconds", "method="+quote(k.Method)+",result="+quote(k.Result))
+	}
+
+	return ew.n, ew.err
+}
+
+// NewService creates a notifications service that records the durations
+// of List, Count, MarkRead, MarkAllRead, MarkReadBatch and MarkUnread calls
+// to service in m.
+//
+// It implements pagination.Lister, filter.Lister and batch.MarkReader, so that
+// native implementations of them by service, if any, are still used.
+// Other optional interfaces of service are available via its Unwrap method.
+func NewService(service notifications.Service, m *Metrics) *Service {
+	return &Service{
+		Service: service,
+		m:       m,
+	}
+}
+
+// Service is a notifications service that records metrics about calls to another one.
+type Service struct {
+	notifications.Service
+
+	m *Metrics
+}
+
+// Unwrap returns the underlying notifications service.
+func (s *Service) Unwrap() notifications.Service { return s.Service }
+
+func (s *Service) List(ctx context.Context, opt notifications.ListOptions) (notifications.Notifications, error) {
+	start := time.Now()
+	ns, err := s.Service.List(ctx, opt)
+	s.m.observeBackend("List", time.Since(start), err)
+	return ns, err
+}
+
+// ListFiltered implements filter.Lister. It's recorded as a List call.
+func (s *Service) ListFiltered(ctx context.Context, opt notifications.ListOptions, f filter.Options) (notifications.Notifications, error) {
+	start := time.Now()
+	ns, err := filter.List(ctx, s.Service, opt, f)
+	s.m.observeBackend("List", time.Since(start), err)
+	return ns, err
+}
+
+// ListPage implements pagination.Lister. It's recorded as a List call.
+func (s *Service) ListPage(ctx context.Context, opt notifications.ListOptions, f filter.Options, p pagination.Options) (pagination.Page, error) {
+	start := time.Now()
+	page, err := pagination.List(ctx, s.Service, opt, f, p)
+	s.m.observeBackend("List", time.Since(start), err)
+	return page, err
+}
+
+func (s *Service) Count(ctx context.Context, opt interface{}) (uint64, error) {
+	start := time.Now()
+	n, err := s.Service.Count(ctx, opt)
+	s.m.observeBackend("Count", time.Since(start), err)
+	return n, err
+}
+
+func (s *Service) MarkRead(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error {
+	start := time.Now()
+	err := s.Service.MarkRead(ctx, repo, threadType, threadID)
+	s.m.observeBackend("MarkRead", time.Since(start), err)
+	return err
+}
+
+func (s *Service) MarkAllRead(ctx context.Context, repo notifications.RepoSpec) error {
+	start := time.Now()
+	err := s.Service.MarkAllRead(ctx, repo)
+	s.m.observeBackend("MarkAllRead", time.Since(start), err)
+	return err
+}
+
+// MarkReadBatch implements batch.MarkReader.
+func (s *Service) MarkReadBatch(ctx context.Context, threads []batch.Thread) ([]batch.Failure, error) {
+	start := time.Now()
+	failures, err := batch.MarkRead(ctx, s.Service, threads)
+	s.m.observeBackend("MarkReadBatch", time.Since(start), err)
+	return failures, err
+}
+
+// MarkUnread implements unread.Marker.
+// It returns unread.ErrNotSupported if no underlying service implements unread.Marker.
+func (s *Service) MarkUnread(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error {
+	m, ok := unwrap.Find[unread.Marker](s.Service)
+	if !ok {
+		return unread.ErrNotSupported
+	}
+	start := time.Now()
+	err := m.MarkUnread(ctx, repo, threadType, threadID)
+	s.m.observeBackend("MarkUnread", time.Since(start), err)
+	return err
+}
+
+// Forwards reports whether s implements the optional interface that target
+// points to by forwarding calls to the underlying service. It does for unread.Marker,
+// so s supports marking threads as unread only if the underlying service does.
+func (s *Service) Forwards(target any) bool {
+	_, ok := target.(*unread.Marker)
+	return ok
+}
+
+// buckets are the upper bounds of histogram buckets, in seconds.
+// They're the same as Prometheus client libraries use by default.
+var buckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// histogram is a histogram of observed values, with buckets.
+type histogram struct {
+	counts []uint64 // Number of observations in each bucket (not cumulative).
+	sum    float64
+	count  uint64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]uint64, len(buckets))}
+}
+
+// Observe adds an observation of value v.
+func (h *histogram) Observe(v float64) {
+	if i := sort.SearchFloat64s(buckets, v); i < len(buckets) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+// write writes h as histogram name with labels to w.
+func (h *histogram) write(w io.Writer, name, labels string) {
+	var cumulative uint64
+	for i, le := range buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// quote returns label value v quoted and escaped for the Prometheus text exposition format.
+func quote(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// errWriter is an io.Writer that counts bytes written to w,
+// and stops writing after the first error.
+type errWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (ew *errWriter) Write(p []byte) (int, error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+	n, err := ew.w.Write(p)
+	ew.n += int64(n)
+	ew.err = err
+	return n, err
+}
+
+// responseWriter wraps a real http.ResponseWriter and captures its status code.
+type responseWriter struct {
+	http.ResponseWriter
+
+	code int // Status code passed to WriteHeader, or 0 if it wasn't called.
+}
+
+func (rw *responseWriter) Write(p []byte) (n int, err error) {
+	if rw.code == 0 {
+		rw.code = http.StatusOK
+	}
+	return rw.ResponseWriter.Write(p)
+}
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.code == 0 {
+		rw.code = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// StatusCode returns the status code of the response.
+// It's 200 OK if WriteHeader wasn't called explicitly.
+func (rw *responseWriter) StatusCode() int {
+	if rw.code == 0 {
+		return http.StatusOK
+	}
+	return rw.code
+}
+
+// Unwrap returns the underlying http.ResponseWriter, so that optional
+// interfaces it implements, like http.Flusher, can be found.
+func (rw *responseWriter) Unwrap() http.ResponseWriter { return rw.ResponseWriter }
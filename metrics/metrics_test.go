@@ -0,0 +1,74 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/batch"
+	"github.com/shurcooL/notificationsapp/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+	h := m.Handler("/api/notifications/list", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("fail") != "" {
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+		}
+	}))
+	for _, target := range []string{"/", "/", "/?fail=1"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+	stream := m.Handler("/api/notifications/stream", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}))
+	stream.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	s := metrics.NewService(failingService{}, m)
+	s.MarkAllRead(context.Background(), notifications.RepoSpec{})
+	if err := s.MarkUnread(context.Background(), notifications.RepoSpec{}, "Issue", 1); err != nil {
+		t.Error("MarkUnread:", err)
+	}
+	if _, err := batch.MarkRead(context.Background(), s, []batch.Thread{{RepoURI: "example.org/repo", ThreadType: "Issue", ThreadID: 1}}); err != nil {
+		t.Error("MarkReadBatch:", err)
+	}
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	got := rr.Body.String()
+	for _, want := range []string{
+		`notificationsapp_http_requests_total{route="/api/notifications/list",code="200"} 2`,
+		`notificationsapp_http_requests_total{route="/api/notifications/list",code="500"} 1`,
+		`notificationsapp_http_request_duration_seconds_bucket{route="/api/notifications/list",le="+Inf"} 3`,
+		`notificationsapp_http_request_duration_seconds_count{route="/api/notifications/list"} 3`,
+		`notificationsapp_http_requests_total{route="/api/notifications/stream",code="200"} 1`,
+		`notificationsapp_backend_call_duration_seconds_count{method="MarkAllRead",result="error"} 1`,
+		`notificationsapp_backend_call_duration_seconds_count{method="MarkUnread",result="success"} 1`,
+		`notificationsapp_backend_call_duration_seconds_count{method="MarkReadBatch",result="success"} 1`,
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("metrics don't contain %q:\n%s", want, got)
+		}
+	}
+	// Streaming responses last as long as the client stays connected, so their durations aren't recorded.
+	if strings.Contains(got, `notificationsapp_http_request_duration_seconds_count{route="/api/notifications/stream"}`) {
+		t.Errorf("metrics contain duration of streaming responses:\n%s", got)
+	}
+}
+
+type failingService struct{ notifications.Service }
+
+func (failingService) MarkAllRead(context.Context, notifications.RepoSpec) error {
+	return errors.New("failed")
+}
+
+func (failingService) MarkRead(context.Context, notifications.RepoSpec, string, uint64) error {
+	return nil
+}
+
+func (failingService) MarkUnread(context.Context, notifications.RepoSpec, string, uint64) error {
+	return nil
+}
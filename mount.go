@@ -0,0 +1,56 @@
+package notificationsapp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/shurcooL/notificationsapp/httphandler"
+	"github.com/shurcooL/notificationsapp/httproute"
+	"github.com/shurcooL/notificationsapp/metrics"
+	"github.com/shurcooL/users"
+)
+
+// Mount registers a notifications app and its HTTP API endpoints on mux,
+// under the path prefix. The app is created with New(api.Notifications, users, opt),
+// and is served at prefix + "/". API endpoints are served at prefix + httproute paths.
+// prefix must be empty or begin with a slash, and must not end with a slash.
+// For example:
+//
+// 	apiHandler := httphandler.Notifications{Notifications: service}
+// 	notificationsapp.Mount(http.DefaultServeMux, "/notifications", apiHandler, users, opt)
+//
+// Mount sets opt.APIBaseURI to prefix, and provides the BaseURIContextKey
+// context key to the app, so there's nothing else the caller needs to do.
+// If opt.Metrics is not nil, api.Notifications is instrumented with metrics.NewService.
+func Mount(mux *http.ServeMux, prefix string, api httphandler.Notifications, users users.Service, opt Options) {
+	if opt.Metrics != nil {
+		api.Notifications = metrics.NewService(api.Notifications, opt.Metrics)
+	}
+	handle := func(route string, h func(w http.ResponseWriter, req *http.Request) error) {
+		var handler http.Handler = APIHandler(h, users, opt)
+		if opt.Metrics != nil {
+			handler = opt.Metrics.Handler(route, handler)
+		}
+		mux.Handle(prefix+route, handler)
+	}
+	handle(httproute.List, api.List)
+	handle(httproute.Count, api.Count)
+	handle(httproute.MarkRead, api.MarkRead)
+	handle(httproute.MarkAllRead, api.MarkAllRead)
+	handle(httproute.MarkReadBatch, api.MarkReadBatch)
+	handle(httproute.MarkUnread, api.MarkUnread)
+	handle(httproute.UndoMarkRead, api.UndoMarkRead)
+	handle(httproute.Snooze, api.Snooze)
+	handle(httproute.Mute, api.Mute)
+	handle(httproute.Unmute, api.Unmute)
+	handle(httproute.Stream, api.Stream)
+	handle(httproute.Notify, api.Notify)
+	handle(httproute.Subscribe, api.Subscribe)
+
+	opt.APIBaseURI = prefix
+	app := New(api.Notifications, users, opt)
+	mux.Handle(prefix+"/", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req = req.WithContext(context.WithValue(req.Context(), BaseURIContextKey, prefix))
+		app.ServeHTTP(w, req)
+	}))
+}
@@ -0,0 +1,185 @@
+// Package mute contains an optional notifications.Service extension for muting
+// threads, and a service that adds it to any notifications.Service.
+package mute
+
+import (
+	"context"
+	"sync"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/batch"
+	"github.com/shurcooL/notificationsapp/internal/authuser"
+	"github.com/shurcooL/notificationsapp/internal/hidden"
+	"github.com/shurcooL/notificationsapp/internal/unwrap"
+	"github.com/shurcooL/users"
+)
+
+// Muter is an optional interface that a notifications.Service
+// can implement to support muting threads.
+type Muter interface {
+	// Mute mutes the specified thread for authenticated user. Notifications
+	// in it are hidden from them, and they're not notified of it anymore.
+	// Returns a permission error if no authenticated user.
+	Mute(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error
+
+	// Unmute unmutes the specified thread for authenticated user.
+	// It undoes the effect of Mute.
+	// Returns a permission error if no authenticated user.
+	Unmute(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error
+}
+
+// Unsubscriber is an optional interface that a notifications.Service
+// can implement to support unsubscribing users from threads.
+type Unsubscriber interface {
+	// Unsubscribe unsubscribes subscribers from the specified thread.
+	// It undoes the effect of Subscribe.
+	// Returns a permission error if no authenticated user.
+	Unsubscribe(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64, subscribers []users.UserSpec) error
+}
+
+// NewService creates a notifications service that adds muting to service.
+// It uses users service to determine the authenticated user.
+// Mutes are kept in memory.
+//
+// Notifications in threads muted by a user are hidden from their List and Count,
+// and the user is skipped when subscribing subscribers to those threads.
+// Muting a thread also unsubscribes the user from it if service or a service it wraps
+// implements Unsubscriber, so that future Notify calls in it skip the user.
+// Otherwise, Notify calls still create notifications for the user, which are hidden.
+func NewService(service notifications.Service, users users.Service) *Service {
+	return &Service{
+		Service: service,
+		users:   users,
+	}
+}
+
+// Service is a notifications service that adds muting to another one.
+type Service struct {
+	notifications.Service
+
+	users authuser.Getter
+
+	mu    sync.Mutex
+	muted map[users.UserSpec]map[batch.Thread]bool // Muted threads by user, and whether Mute unsubscribed the user.
+}
+
+// Unwrap returns the underlying notifications service.
+func (s *Service) Unwrap() notifications.Service { return s.Service }
+
+// Mute implements Muter.
+func (s *Service) Mute(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error {
+	user, err := authuser.Get(ctx, s.users)
+	if err != nil {
+		return err
+	}
+	unsubscribed := false
+	if u, ok := unwrap.Find[Unsubscriber](s.Service); ok {
+		err := u.Unsubscribe(ctx, repo, threadType, threadID, []users.UserSpec{user})
+		if err != nil {
+			return err
+		}
+		unsubscribed = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.muted == nil {
+		s.muted = make(map[users.UserSpec]map[batch.Thread]bool)
+	}
+	if s.muted[user] == nil {
+		s.muted[user] = make(map[batch.Thread]bool)
+	}
+	s.muted[user][batch.Thread{RepoURI: repo.URI, ThreadType: threadType, ThreadID: threadID}] = unsubscribed
+	return nil
+}
+
+// Unmute implements Muter. If Mute unsubscribed authenticated user
+// from the thread, they're subscribed to it again.
+func (s *Service) Unmute(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error {
+	user, err := authuser.Get(ctx, s.users)
+	if err != nil {
+		return err
+	}
+	t := batch.Thread{RepoURI: repo.URI, ThreadType: threadType, ThreadID: threadID}
+	s.mu.Lock()
+	unsubscribed, ok := s.muted[user][t]
+	delete(s.muted[user], t)
+	s.mu.Unlock()
+	if !ok || !unsubscribed {
+		return nil
+	}
+	return s.Service.Subscribe(ctx, repo, threadType, threadID, []users.UserSpec{user})
+}
+
+// List lists notifications for authenticated user, except for ones in muted threads.
+func (s *Service) List(ctx context.Context, opt notifications.ListOptions) (notifications.Notifications, error) {
+	muted, err := s.mutedThreads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ns, err := s.Service.List(ctx, opt)
+	if err != nil || len(muted) == 0 {
+		return ns, err
+	}
+	visible := make(notifications.Notifications, 0, len(ns))
+	for _, n := range ns {
+		if muted[batch.Thread{RepoURI: n.RepoSpec.URI, ThreadType: n.ThreadType, ThreadID: n.ThreadID}] {
+			continue
+		}
+		visible = append(visible, n)
+	}
+	return visible, nil
+}
+
+// Count counts notifications for authenticated user, except for ones in muted threads.
+func (s *Service) Count(ctx context.Context, opt interface{}) (uint64, error) {
+	muted, err := s.mutedThreads(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count, err := s.Service.Count(ctx, opt)
+	if err != nil || len(muted) == 0 {
+		return count, err
+	}
+	n, err := hidden.CountUnread(ctx, s.Service, muted)
+	if err != nil {
+		return 0, err
+	}
+	if n > count {
+		return 0, nil
+	}
+	return count - n, nil
+}
+
+// Subscribe subscribes subscribers to the specified thread,
+// except for the ones that muted it.
+func (s *Service) Subscribe(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64, subscribers []users.UserSpec) error {
+	t := batch.Thread{RepoURI: repo.URI, ThreadType: threadType, ThreadID: threadID}
+	var unmuted []users.UserSpec
+	s.mu.Lock()
+	for _, u := range subscribers {
+		if _, ok := s.muted[u][t]; ok {
+			continue
+		}
+		unmuted = append(unmuted, u)
+	}
+	s.mu.Unlock()
+	if len(unmuted) == 0 {
+		return nil
+	}
+	return s.Service.Subscribe(ctx, repo, threadType, threadID, unmuted)
+}
+
+// mutedThreads returns the set of threads muted by authenticated user.
+func (s *Service) mutedThreads(ctx context.Context) (map[batch.Thread]bool, error) {
+	user, err := authuser.Get(ctx, s.users)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	muted := make(map[batch.Thread]bool, len(s.muted[user]))
+	for t := range s.muted[user] {
+		muted[t] = true
+	}
+	return muted, nil
+}
@@ -0,0 +1,138 @@
+package mute_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/mute"
+	"github.com/shurcooL/users"
+)
+
+func TestService(t *testing.T) {
+	gopher := users.UserSpec{ID: 1, Domain: "example.org"}
+	other := users.UserSpec{ID: 2, Domain: "example.org"}
+	repo := notifications.RepoSpec{URI: "github.com/a/b"}
+	ms := &mockNotifications{ns: notifications.Notifications{
+		{RepoSpec: repo, ThreadType: "Issue", ThreadID: 1},
+		{RepoSpec: repo, ThreadType: "Issue", ThreadID: 2},
+	}}
+	s := mute.NewService(ms, mockUsers{Current: gopher})
+	ctx := context.Background()
+
+	err := s.Mute(ctx, repo, "Issue", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns, err := s.List(ctx, notifications.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(ns), 1; got != want {
+		t.Fatalf("got %v notifications, want %v", got, want)
+	}
+	if got, want := ns[0].ThreadID, uint64(2); got != want {
+		t.Errorf("got thread %v, want %v", got, want)
+	}
+	if got, want := ms.ns[0].ThreadID, uint64(1); got != want {
+		t.Errorf("List modified notifications of the underlying service: got thread %v, want %v", got, want)
+	}
+	ms.lists = 0
+	count, err := s.Count(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, uint64(1); got != want {
+		t.Errorf("got count %v, want %v", got, want)
+	}
+	if got, want := ms.lists, 1; got != want {
+		t.Errorf("got %v List calls for Count, want %v (one per repository with muted threads)", got, want)
+	}
+
+	err = s.Subscribe(ctx, repo, "Issue", 1, []users.UserSpec{gopher, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ms.subscribed, []users.UserSpec{other}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got subscribers %v, want %v", got, want)
+	}
+}
+
+func TestServiceUnsubscribe(t *testing.T) {
+	gopher := users.UserSpec{ID: 1, Domain: "example.org"}
+	repo := notifications.RepoSpec{URI: "github.com/a/b"}
+	ms := &mockUnsubscriber{mockNotifications: &mockNotifications{ns: notifications.Notifications{
+		{RepoSpec: repo, ThreadType: "Issue", ThreadID: 1},
+	}}}
+	s := mute.NewService(ms, mockUsers{Current: gopher})
+	ctx := context.Background()
+
+	// Muting a thread unsubscribes the user from it, so future Notify calls skip them.
+	err := s.Mute(ctx, repo, "Issue", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ms.unsubscribed, []users.UserSpec{gopher}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got unsubscribed %v, want %v", got, want)
+	}
+
+	// Unmuting it subscribes them again.
+	err = s.Unmute(ctx, repo, "Issue", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ms.subscribed, []users.UserSpec{gopher}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got subscribers %v, want %v", got, want)
+	}
+	ns, err := s.List(ctx, notifications.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(ns), 1; got != want {
+		t.Errorf("got %v notifications, want %v", got, want)
+	}
+}
+
+type mockNotifications struct {
+	notifications.ExternalService
+	ns         notifications.Notifications
+	subscribed []users.UserSpec
+	lists      int // Number of List calls.
+}
+
+func (m *mockNotifications) List(context.Context, notifications.ListOptions) (notifications.Notifications, error) {
+	m.lists++
+	return m.ns, nil
+}
+
+func (m *mockNotifications) Count(context.Context, interface{}) (uint64, error) {
+	return uint64(len(m.ns)), nil
+}
+
+func (*mockNotifications) MarkAllRead(context.Context, notifications.RepoSpec) error { return nil }
+
+func (m *mockNotifications) Subscribe(_ context.Context, _ notifications.RepoSpec, _ string, _ uint64, subscribers []users.UserSpec) error {
+	m.subscribed = append(m.subscribed, subscribers...)
+	return nil
+}
+
+type mockUnsubscriber struct {
+	*mockNotifications
+	unsubscribed []users.UserSpec
+}
+
+func (m *mockUnsubscriber) Unsubscribe(_ context.Context, _ notifications.RepoSpec, _ string, _ uint64, subscribers []users.UserSpec) error {
+	m.unsubscribed = append(m.unsubscribed, subscribers...)
+	return nil
+}
+
+type mockUsers struct {
+	users.Service
+	Current users.UserSpec
+}
+
+func (m mockUsers) GetAuthenticatedSpec(context.Context) (users.UserSpec, error) {
+	return m.Current, nil
+}
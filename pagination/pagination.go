@@ -0,0 +1,158 @@
+// Package pagination contains cursor-based pagination of notifications.
+package pagination
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/filter"
+)
+
+// Options for paginating notifications.
+type Options struct {
+	// Size is the maximum number of notifications in a page.
+	// Zero means no limit.
+	Size int
+
+	// Cursor is an opaque value returned as Page.NextCursor
+	// of the previous page. It's empty for the first page.
+	Cursor string
+}
+
+// Page is a page of notifications.
+type Page struct {
+	Notifications notifications.Notifications
+
+	// NextCursor is the cursor for fetching the next page.
+	// It's empty if there are no more pages.
+	NextCursor string
+}
+
+// Lister is an optional interface that a notifications.Service
+// can implement to perform pagination natively.
+type Lister interface {
+	// ListPage lists a page of notifications for authenticated user that match f.
+	// Returns a permission error if no authenticated user.
+	ListPage(ctx context.Context, opt notifications.ListOptions, f filter.Options, p Options) (Page, error)
+}
+
+// List lists a page of notifications in service that match f.
+// If service implements Lister, it's used to perform pagination natively.
+// Otherwise, notifications returned by filter.List are paginated in memory.
+func List(ctx context.Context, service notifications.Service, opt notifications.ListOptions, f filter.Options, p Options) (Page, error) {
+	if l, ok := service.(Lister); ok {
+		return l.ListPage(ctx, opt, f, p)
+	}
+	ns, err := filter.List(ctx, service, opt, f)
+	if err != nil {
+		return Page{}, err
+	}
+	return Paginate(ns, p)
+}
+
+// Paginate returns the page of notifications ns specified by p.
+// Notifications are ordered by most recently updated first,
+// with ties broken by thread identity. Paginate sorts a copy of ns,
+// since ns may be shared by the service that returned it.
+//
+// It returns an error of type InvalidCursorError if p.Cursor is not valid.
+func Paginate(ns notifications.Notifications, p Options) (Page, error) {
+	ns = append(make(notifications.Notifications, 0, len(ns)), ns...)
+	sort.Sort(byUpdatedAt(ns))
+	if p.Cursor != "" {
+		c, err := decodeCursor(p.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		// Skip notifications up to and including the one at cursor.
+		i := sort.Search(len(ns), func(i int) bool { return c.before(ns[i]) })
+		ns = ns[i:]
+	}
+	if p.Size <= 0 || len(ns) <= p.Size {
+		return Page{Notifications: ns}, nil
+	}
+	return Page{
+		Notifications: ns[:p.Size],
+		NextCursor:    encodeCursor(ns[p.Size-1]),
+	}, nil
+}
+
+// InvalidCursorError is returned when a cursor is not valid.
+type InvalidCursorError struct {
+	Cursor string
+	Err    error
+}
+
+func (e InvalidCursorError) Error() string {
+	return fmt.Sprintf("invalid cursor %q: %v", e.Cursor, e.Err)
+}
+
+// cursor identifies the position of a notification in the order used by Paginate.
+type cursor struct {
+	UpdatedAt  time.Time
+	RepoURI    string
+	ThreadType string
+	ThreadID   uint64
+}
+
+// before reports whether the notification at c comes before n.
+func (c cursor) before(n notifications.Notification) bool {
+	return less(c, cursorOf(n))
+}
+
+func cursorOf(n notifications.Notification) cursor {
+	return cursor{
+		UpdatedAt:  n.UpdatedAt,
+		RepoURI:    n.RepoSpec.URI,
+		ThreadType: n.ThreadType,
+		ThreadID:   n.ThreadID,
+	}
+}
+
+func encodeCursor(n notifications.Notification) string {
+	b, err := json.Marshal(cursorOf(n))
+	if err != nil {
+		panic(fmt.Errorf("internal error: json.Marshal: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, InvalidCursorError{Cursor: s, Err: err}
+	}
+	var c cursor
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return cursor{}, InvalidCursorError{Cursor: s, Err: err}
+	}
+	return c, nil
+}
+
+// less reports whether a comes before b.
+// Most recently updated comes first, ties are broken by thread identity.
+func less(a, b cursor) bool {
+	switch {
+	case !a.UpdatedAt.Equal(b.UpdatedAt):
+		return a.UpdatedAt.After(b.UpdatedAt)
+	case a.RepoURI != b.RepoURI:
+		return a.RepoURI < b.RepoURI
+	case a.ThreadType != b.ThreadType:
+		return a.ThreadType < b.ThreadType
+	default:
+		return a.ThreadID < b.ThreadID
+	}
+}
+
+// byUpdatedAt implements sort.Interface.
+type byUpdatedAt []notifications.Notification
+
+func (s byUpdatedAt) Len() int           { return len(s) }
+func (s byUpdatedAt) Less(i, j int) bool { return less(cursorOf(s[i]), cursorOf(s[j])) }
+func (s byUpdatedAt) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,58 @@
+package pagination_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/pagination"
+)
+
+func TestPaginate(t *testing.T) {
+	t0 := time.Date(2017, 2, 4, 0, 0, 0, 0, time.UTC)
+	ns := notifications.Notifications{
+		{RepoSpec: notifications.RepoSpec{URI: "a"}, ThreadType: "Issue", ThreadID: 1, UpdatedAt: t0},
+		{RepoSpec: notifications.RepoSpec{URI: "b"}, ThreadType: "Issue", ThreadID: 1, UpdatedAt: t0.Add(time.Hour)},
+		{RepoSpec: notifications.RepoSpec{URI: "a"}, ThreadType: "Issue", ThreadID: 2, UpdatedAt: t0},
+		{RepoSpec: notifications.RepoSpec{URI: "c"}, ThreadType: "PullRequest", ThreadID: 3, UpdatedAt: t0.Add(2 * time.Hour)},
+		{RepoSpec: notifications.RepoSpec{URI: "a"}, ThreadType: "PullRequest", ThreadID: 1, UpdatedAt: t0},
+	}
+	want := []uint64{3, 1, 1, 2, 1} // ThreadIDs in expected order.
+
+	var (
+		got    []uint64
+		cursor string
+		pages  int
+	)
+	for {
+		page, err := pagination.Paginate(ns, pagination.Options{Size: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, n := range page.Notifications {
+			got = append(got, n.ThreadID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if want := 3; pages != want {
+		t.Errorf("got %v pages, want %v", pages, want)
+	}
+	if got, want := ns[0].RepoSpec.URI, "a"; got != want {
+		t.Errorf("Paginate modified its input: got first repo %q, want %q", got, want)
+	}
+}
+
+func TestPaginateInvalidCursor(t *testing.T) {
+	_, err := pagination.Paginate(nil, pagination.Options{Cursor: "!"})
+	if _, ok := err.(pagination.InvalidCursorError); !ok {
+		t.Errorf("got error %v of type %T, want pagination.InvalidCursorError", err, err)
+	}
+}
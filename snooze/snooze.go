@@ -0,0 +1,275 @@
+// Package snooze contains an optional notifications.Service extension for snoozing
+// threads until a chosen time, and a service that adds it to any notifications.Service.
+package snooze
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/batch"
+	"github.com/shurcooL/notificationsapp/internal/authuser"
+	"github.com/shurcooL/notificationsapp/internal/hidden"
+	"github.com/shurcooL/notificationsapp/internal/unwrap"
+	"github.com/shurcooL/notificationsapp/unread"
+	"github.com/shurcooL/users"
+)
+
+// Snoozer is an optional interface that a notifications.Service
+// can implement to support snoozing threads.
+type Snoozer interface {
+	// Snooze snoozes the specified thread until the specified time.
+	// Snoozed threads are hidden until then, after which they resurface as unread.
+	// Returns a permission error if no authenticated user.
+	Snooze(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64, until time.Time) error
+}
+
+// Choice is a choice of how long to snooze for, as offered in the UI.
+type Choice struct {
+	ID    string // ID of the choice. E.g., "tomorrow".
+	Label string // Human readable label, in the default language of package i18n. E.g., "Tomorrow".
+}
+
+// Choices of how long to snooze for, in display order.
+var Choices = []Choice{
+	{ID: "later-today", Label: "Later today"},
+	{ID: "tomorrow", Label: "Tomorrow"},
+	{ID: "next-week", Label: "Next week"},
+}
+
+// ChoiceByID returns the choice with the specified ID.
+func ChoiceByID(id string) (Choice, bool) {
+	for _, c := range Choices {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Choice{}, false
+}
+
+// morning is the hour of the day at which snoozed threads resurface,
+// when snoozed until another day.
+const morning = 9
+
+// Until returns the time until which to snooze, when snoozing with choice c at time now.
+// Times of day are in the location of now.
+func (c Choice) Until(now time.Time) time.Time {
+	switch c.ID {
+	case "later-today":
+		return now.Add(3 * time.Hour)
+	case "tomorrow":
+		y, m, d := now.Date()
+		return time.Date(y, m, d+1, morning, 0, 0, 0, now.Location())
+	case "next-week":
+		y, m, d := now.Date()
+		days := (int(time.Monday) - int(now.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return time.Date(y, m, d+days, morning, 0, 0, 0, now.Location())
+	default:
+		return now
+	}
+}
+
+// NewService creates a notifications service that adds snoozing to service.
+// It uses users service to determine the authenticated user.
+// Snoozes are kept in memory.
+//
+// Snoozed threads are hidden from List and Count. Once a snooze expires,
+// the thread resurfaces, and is marked as unread if service or a service it wraps
+// implements unread.Marker.
+func NewService(service notifications.Service, users users.Service) *Service {
+	return &Service{
+		Service: service,
+		users:   users,
+		now:     time.Now,
+	}
+}
+
+// Service is a notifications service that adds snoozing to another one.
+type Service struct {
+	notifications.Service
+
+	users authuser.Getter
+	now   func() time.Time
+
+	mu      sync.Mutex
+	snoozes map[users.UserSpec]map[batch.Thread]time.Time // Snooze end times by user and thread.
+}
+
+// Unwrap returns the underlying notifications service.
+func (s *Service) Unwrap() notifications.Service { return s.Service }
+
+// Snooze implements Snoozer.
+func (s *Service) Snooze(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64, until time.Time) error {
+	user, err := authuser.Get(ctx, s.users)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.snoozes == nil {
+		s.snoozes = make(map[users.UserSpec]map[batch.Thread]time.Time)
+	}
+	if s.snoozes[user] == nil {
+		s.snoozes[user] = make(map[batch.Thread]time.Time)
+	}
+	s.snoozes[user][batch.Thread{RepoURI: repo.URI, ThreadType: threadType, ThreadID: threadID}] = until
+	return nil
+}
+
+// List lists notifications for authenticated user, except for snoozed threads.
+func (s *Service) List(ctx context.Context, opt notifications.ListOptions) (notifications.Notifications, error) {
+	snoozed, err := s.resurface(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ns, err := s.Service.List(ctx, opt)
+	if err != nil || len(snoozed) == 0 {
+		return ns, err
+	}
+	visible := make(notifications.Notifications, 0, len(ns))
+	for _, n := range ns {
+		if snoozed[batch.Thread{RepoURI: n.RepoSpec.URI, ThreadType: n.ThreadType, ThreadID: n.ThreadID}] {
+			continue
+		}
+		visible = append(visible, n)
+	}
+	return visible, nil
+}
+
+// Count counts notifications for authenticated user, except for snoozed threads.
+func (s *Service) Count(ctx context.Context, opt interface{}) (uint64, error) {
+	snoozed, err := s.resurface(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count, err := s.Service.Count(ctx, opt)
+	if err != nil || len(snoozed) == 0 {
+		return count, err
+	}
+	n, err := hidden.CountUnread(ctx, s.Service, snoozed)
+	if err != nil {
+		return 0, err
+	}
+	if n > count {
+		return 0, nil
+	}
+	return count - n, nil
+}
+
+// MarkRead marks the specified thread as read, and cancels its snooze, if any.
+func (s *Service) MarkRead(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error {
+	err := s.Service.MarkRead(ctx, repo, threadType, threadID)
+	if err != nil {
+		return err
+	}
+	s.unsnooze(ctx, func(t batch.Thread) bool {
+		return t == batch.Thread{RepoURI: repo.URI, ThreadType: threadType, ThreadID: threadID}
+	})
+	return nil
+}
+
+// MarkAllRead marks all notifications in the specified repository as read,
+// and cancels snoozes of threads in it.
+func (s *Service) MarkAllRead(ctx context.Context, repo notifications.RepoSpec) error {
+	err := s.Service.MarkAllRead(ctx, repo)
+	if err != nil {
+		return err
+	}
+	s.unsnooze(ctx, func(t batch.Thread) bool {
+		return t.RepoURI == repo.URI
+	})
+	return nil
+}
+
+// MarkUnread implements unread.Marker. It cancels the snooze of the thread, if any.
+// It returns unread.ErrNotSupported if no underlying service implements unread.Marker.
+func (s *Service) MarkUnread(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error {
+	m, ok := unwrap.Find[unread.Marker](s.Service)
+	if !ok {
+		return unread.ErrNotSupported
+	}
+	err := m.MarkUnread(ctx, repo, threadType, threadID)
+	if err != nil {
+		return err
+	}
+	s.unsnooze(ctx, func(t batch.Thread) bool {
+		return t == batch.Thread{RepoURI: repo.URI, ThreadType: threadType, ThreadID: threadID}
+	})
+	return nil
+}
+
+// Forwards reports true for a *unread.Marker target, since MarkUnread
+// is forwarded to the underlying service. See unread.ErrNotSupported.
+func (s *Service) Forwards(target any) bool {
+	_, ok := target.(*unread.Marker)
+	return ok
+}
+
+// resurface resurfaces threads of authenticated user whose snoozes have expired,
+// and returns the set of threads that remain snoozed.
+//
+// A snooze is removed only once its thread is marked as unread, so that it's
+// retried by a later call if that fails. Failures don't stop other threads
+// from resurfacing.
+func (s *Service) resurface(ctx context.Context) (map[batch.Thread]bool, error) {
+	user, err := authuser.Get(ctx, s.users)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	var (
+		snoozed = make(map[batch.Thread]bool)
+		expired = make(map[batch.Thread]time.Time)
+	)
+	s.mu.Lock()
+	for t, until := range s.snoozes[user] {
+		if now.Before(until) {
+			snoozed[t] = true
+			continue
+		}
+		expired[t] = until
+	}
+	s.mu.Unlock()
+	if len(expired) == 0 {
+		return snoozed, nil
+	}
+
+	if m, ok := unwrap.Find[unread.Marker](s.Service); ok {
+		for t := range expired {
+			err := m.MarkUnread(ctx, notifications.RepoSpec{URI: t.RepoURI}, t.ThreadType, t.ThreadID)
+			if err != nil {
+				// Keep the snooze, so that marking the thread as unread is retried.
+				delete(expired, t)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	for t, until := range expired {
+		// Leave the snooze if the thread was snoozed again in the meantime.
+		if s.snoozes[user][t].Equal(until) {
+			delete(s.snoozes[user], t)
+		}
+	}
+	s.mu.Unlock()
+	return snoozed, nil
+}
+
+// unsnooze cancels snoozes of threads of authenticated user that match.
+func (s *Service) unsnooze(ctx context.Context, match func(batch.Thread) bool) {
+	user, err := authuser.Get(ctx, s.users)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t := range s.snoozes[user] {
+		if match(t) {
+			delete(s.snoozes[user], t)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package snooze_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/snooze"
+	"github.com/shurcooL/users"
+)
+
+func TestService(t *testing.T) {
+	repo := notifications.RepoSpec{URI: "github.com/a/b"}
+	ms := &mockNotifications{ns: notifications.Notifications{
+		{RepoSpec: repo, ThreadType: "Issue", ThreadID: 1},
+		{RepoSpec: repo, ThreadType: "Issue", ThreadID: 2},
+		{RepoSpec: repo, ThreadType: "Issue", ThreadID: 3},
+	}}
+	s := snooze.NewService(ms, mockUsers{Current: users.UserSpec{ID: 1, Domain: "example.org"}})
+	ctx := context.Background()
+
+	err := s.Snooze(ctx, repo, "Issue", 1, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Snooze(ctx, repo, "Issue", 2, time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns, err := s.List(ctx, notifications.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := threadIDs(ns), []uint64{2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got threads %v, want %v", got, want)
+	}
+	if got, want := ms.unread, []uint64{2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got threads marked as unread %v, want %v", got, want)
+	}
+	if got, want := threadIDs(ms.ns), []uint64{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List modified notifications of the underlying service: got threads %v, want %v", got, want)
+	}
+	ms.lists = 0
+	count, err := s.Count(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, uint64(2); got != want {
+		t.Errorf("got count %v, want %v", got, want)
+	}
+	if got, want := ms.lists, 1; got != want {
+		t.Errorf("got %v List calls for Count, want %v (one per repository with snoozed threads)", got, want)
+	}
+
+	// Marking a snoozed thread as read cancels its snooze.
+	err = s.MarkRead(ctx, repo, "Issue", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns, err = s.List(ctx, notifications.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := threadIDs(ns), []uint64{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got threads %v, want %v", got, want)
+	}
+}
+
+func TestServiceResurfaceRetry(t *testing.T) {
+	repo := notifications.RepoSpec{URI: "github.com/a/b"}
+	ms := &mockNotifications{
+		ns: notifications.Notifications{
+			{RepoSpec: repo, ThreadType: "Issue", ThreadID: 1},
+			{RepoSpec: repo, ThreadType: "Issue", ThreadID: 2},
+		},
+		failUnread: map[uint64]bool{1: true},
+	}
+	s := snooze.NewService(ms, mockUsers{Current: users.UserSpec{ID: 1, Domain: "example.org"}})
+	ctx := context.Background()
+	for _, id := range []uint64{1, 2} {
+		err := s.Snooze(ctx, repo, "Issue", id, time.Now().Add(-time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A failure to mark one thread as unread doesn't stop the other from resurfacing.
+	_, err := s.List(ctx, notifications.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ms.unread, []uint64{2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got threads marked as unread %v, want %v", got, want)
+	}
+
+	// The failed one is retried, and each thread is marked as unread only once.
+	ms.failUnread = nil
+	for i := 0; i < 2; i++ {
+		_, err = s.List(ctx, notifications.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := ms.unread, []uint64{2, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got threads marked as unread %v, want %v", got, want)
+	}
+}
+
+func TestChoiceUntil(t *testing.T) {
+	now := time.Date(2017, 2, 3, 14, 30, 0, 0, time.UTC) // A Friday.
+	tests := []struct {
+		id   string
+		want time.Time
+	}{
+		{"later-today", time.Date(2017, 2, 3, 17, 30, 0, 0, time.UTC)},
+		{"tomorrow", time.Date(2017, 2, 4, 9, 0, 0, 0, time.UTC)},
+		{"next-week", time.Date(2017, 2, 6, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		c, ok := snooze.ChoiceByID(tc.id)
+		if !ok {
+			t.Fatalf("choice %q not found", tc.id)
+		}
+		if got := c.Until(now); !got.Equal(tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.id, got, tc.want)
+		}
+	}
+}
+
+type mockNotifications struct {
+	notifications.ExternalService
+	ns         notifications.Notifications
+	unread     []uint64        // IDs of threads marked as unread.
+	failUnread map[uint64]bool // IDs of threads that fail to be marked as unread.
+	lists      int             // Number of List calls.
+}
+
+func (m *mockNotifications) List(context.Context, notifications.ListOptions) (notifications.Notifications, error) {
+	m.lists++
+	return m.ns, nil
+}
+
+func (m *mockNotifications) Count(context.Context, interface{}) (uint64, error) {
+	return uint64(len(m.ns)), nil
+}
+
+func (*mockNotifications) MarkRead(context.Context, notifications.RepoSpec, string, uint64) error {
+	return nil
+}
+
+func (*mockNotifications) MarkAllRead(context.Context, notifications.RepoSpec) error { return nil }
+
+func (m *mockNotifications) MarkUnread(_ context.Context, _ notifications.RepoSpec, _ string, threadID uint64) error {
+	if m.failUnread[threadID] {
+		return errors.New("temporary failure")
+	}
+	m.unread = append(m.unread, threadID)
+	return nil
+}
+
+type mockUsers struct {
+	users.Service
+	Current users.UserSpec
+}
+
+func (m mockUsers) GetAuthenticatedSpec(context.Context) (users.UserSpec, error) {
+	return m.Current, nil
+}
+
+func threadIDs(ns notifications.Notifications) []uint64 {
+	var ids []uint64
+	for _, n := range ns {
+		ids = append(ids, n.ThreadID)
+	}
+	return ids
+}
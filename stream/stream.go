@@ -0,0 +1,240 @@
+// Package stream contains a source of live notification events
+// that a notifications.Service backend can feed, for streaming to clients.
+// It also contains a service that feeds it with changes made via any notifications.Service.
+package stream
+
+import (
+	"context"
+	"sync"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/batch"
+	"github.com/shurcooL/notificationsapp/internal/authuser"
+	"github.com/shurcooL/notificationsapp/internal/unwrap"
+	"github.com/shurcooL/notificationsapp/unread"
+	"github.com/shurcooL/users"
+)
+
+// Event types.
+const (
+	Notify = "notify" // A notification was created or updated.
+	Read   = "read"   // A thread was marked as read.
+	Unread = "unread" // A thread was marked as unread.
+)
+
+// Event is an event about a notification.
+type Event struct {
+	Type string // Event type. E.g., Notify or Read.
+
+	// Notification that the event is about. For Read events, only RepoSpec,
+	// ThreadType and ThreadID are set. If ThreadType and ThreadID are zero,
+	// all threads in the repository were marked as read. For Unread events,
+	// only RepoSpec, ThreadType and ThreadID are set if the notification
+	// couldn't be listed.
+	Notification notifications.Notification
+}
+
+// Source is a source of notification events.
+type Source interface {
+	// Subscribe subscribes to events about notifications of authenticated user.
+	// The returned channel is closed when ctx is done.
+	// Returns a permission error if no authenticated user.
+	Subscribe(ctx context.Context) (<-chan Event, error)
+}
+
+// Hub is a Source that a notifications.Service backend feeds
+// by publishing events as they happen.
+//
+// NewService can be used to publish Read and Unread events. Notify events
+// are published by the backend where it creates a notification, to each user
+// who gets it, since only the backend knows who that is.
+type Hub struct {
+	users authuser.Getter
+
+	mu   sync.Mutex
+	subs map[users.UserSpec]map[chan Event]struct{} // Subscribers by user.
+}
+
+// NewHub creates a hub that uses users service to determine
+// the authenticated user of a subscriber.
+func NewHub(users users.Service) *Hub {
+	return &Hub{users: users}
+}
+
+// subscriberBuffer is the number of events buffered for each subscriber.
+const subscriberBuffer = 16
+
+// Subscribe implements Source.
+func (h *Hub) Subscribe(ctx context.Context) (<-chan Event, error) {
+	user, err := authuser.Get(ctx, h.users)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	h.mu.Lock()
+	if h.subs == nil {
+		h.subs = make(map[users.UserSpec]map[chan Event]struct{})
+	}
+	if h.subs[user] == nil {
+		h.subs[user] = make(map[chan Event]struct{})
+	}
+	h.subs[user][ch] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(h.subs[user], ch)
+		if len(h.subs[user]) == 0 {
+			delete(h.subs, user)
+		}
+		close(ch)
+		h.mu.Unlock()
+	}()
+	return ch, nil
+}
+
+// Publish publishes event e about a notification of user to its subscribers.
+// It doesn't block. Events are dropped for subscribers that aren't keeping up.
+func (h *Hub) Publish(user users.UserSpec, e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[user] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// NewService creates a notifications service that publishes events
+// about changes made via it to service to hub.
+//
+// Marking threads as read or unread publishes Read or Unread events to
+// the authenticated user. Notify events aren't published, since it's not
+// known which users get a notification. See Hub.
+//
+// Services that mark threads as unread via the service they wrap, such as undo.Service,
+// need to wrap the returned service, rather than be wrapped by it, for events
+// to be published about threads they mark as unread.
+func NewService(service notifications.Service, hub *Hub) *Service {
+	return &Service{
+		Service: service,
+		hub:     hub,
+	}
+}
+
+// Service is a notifications service that publishes events about changes made via it to a Hub.
+type Service struct {
+	notifications.Service
+
+	hub *Hub
+}
+
+// Unwrap returns the underlying notifications service.
+func (s *Service) Unwrap() notifications.Service { return s.Service }
+
+// MarkRead marks the specified thread as read, and publishes a Read event.
+func (s *Service) MarkRead(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error {
+	err := s.Service.MarkRead(ctx, repo, threadType, threadID)
+	if err != nil {
+		return err
+	}
+	s.publishRead(ctx, batch.Thread{RepoURI: repo.URI, ThreadType: threadType, ThreadID: threadID})
+	return nil
+}
+
+// MarkAllRead marks all notifications in the specified repository as read,
+// and publishes a Read event.
+func (s *Service) MarkAllRead(ctx context.Context, repo notifications.RepoSpec) error {
+	err := s.Service.MarkAllRead(ctx, repo)
+	if err != nil {
+		return err
+	}
+	s.publishRead(ctx, batch.Thread{RepoURI: repo.URI})
+	return nil
+}
+
+// MarkReadBatch implements batch.MarkReader. It publishes a Read event
+// for each thread marked as read.
+func (s *Service) MarkReadBatch(ctx context.Context, threads []batch.Thread) ([]batch.Failure, error) {
+	failures, err := batch.MarkRead(ctx, s.Service, threads)
+	if err != nil {
+		return nil, err
+	}
+	failed := make(map[batch.Thread]bool, len(failures))
+	for _, f := range failures {
+		failed[f.Thread] = true
+	}
+	for _, t := range threads {
+		if failed[t] {
+			continue
+		}
+		s.publishRead(ctx, t)
+	}
+	return failures, nil
+}
+
+// MarkUnread implements unread.Marker, and publishes an Unread event.
+// It returns unread.ErrNotSupported if no underlying service implements unread.Marker.
+func (s *Service) MarkUnread(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error {
+	m, ok := unwrap.Find[unread.Marker](s.Service)
+	if !ok {
+		return unread.ErrNotSupported
+	}
+	err := m.MarkUnread(ctx, repo, threadType, threadID)
+	if err != nil {
+		return err
+	}
+	user, err := authuser.Get(ctx, s.hub.users)
+	if err != nil {
+		return nil
+	}
+	n := notifications.Notification{RepoSpec: repo, ThreadType: threadType, ThreadID: threadID}
+	if listed, ok := s.find(ctx, repo, threadType, threadID); ok {
+		n = listed
+	}
+	s.hub.Publish(user, Event{Type: Unread, Notification: n})
+	return nil
+}
+
+// Forwards reports whether target points to an optional interface
+// that s implements only by forwarding calls to the underlying service.
+// That's the case for unread.Marker.
+func (s *Service) Forwards(target any) bool {
+	_, ok := target.(*unread.Marker)
+	return ok
+}
+
+// find finds the unread notification in the specified thread for the authenticated user.
+// Events are best effort, so it reports false if notifications can't be listed.
+func (s *Service) find(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) (notifications.Notification, bool) {
+	ns, err := s.Service.List(ctx, notifications.ListOptions{Repo: &repo})
+	if err != nil {
+		return notifications.Notification{}, false
+	}
+	for _, n := range ns {
+		if n.ThreadType == threadType && n.ThreadID == threadID {
+			return n, true
+		}
+	}
+	return notifications.Notification{}, false
+}
+
+// publishRead publishes a Read event about thread t to the authenticated user.
+// See Event for the meaning of t with zero ThreadType and ThreadID.
+func (s *Service) publishRead(ctx context.Context, t batch.Thread) {
+	user, err := authuser.Get(ctx, s.hub.users)
+	if err != nil {
+		return
+	}
+	s.hub.Publish(user, Event{
+		Type: Read,
+		Notification: notifications.Notification{
+			RepoSpec:   notifications.RepoSpec{URI: t.RepoURI},
+			ThreadType: t.ThreadType,
+			ThreadID:   t.ThreadID,
+		},
+	})
+}
@@ -0,0 +1,195 @@
+package stream_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/batch"
+	"github.com/shurcooL/notificationsapp/stream"
+	"github.com/shurcooL/notificationsapp/undo"
+	"github.com/shurcooL/users"
+)
+
+func TestHub(t *testing.T) {
+	gopher := users.UserSpec{ID: 1, Domain: "example.org"}
+	other := users.UserSpec{ID: 2, Domain: "example.org"}
+	hub := stream.NewHub(mockUsers{Current: gopher})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := hub.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := stream.Event{
+		Type:         stream.Read,
+		Notification: notifications.Notification{RepoSpec: notifications.RepoSpec{URI: "github.com/a/b"}, ThreadType: "Issue", ThreadID: 1},
+	}
+	hub.Publish(other, stream.Event{Type: stream.Notify})
+	hub.Publish(gopher, want)
+	if got := <-events; !reflect.DeepEqual(got, want) {
+		t.Errorf("got event %+v, want %+v", got, want)
+	}
+
+	cancel()
+	if e, ok := <-events; ok {
+		t.Errorf("got unexpected event %+v after ctx is done", e)
+	}
+}
+
+func TestHubUnauthenticated(t *testing.T) {
+	hub := stream.NewHub(mockUsers{})
+	_, err := hub.Subscribe(context.Background())
+	if err == nil {
+		t.Error("got nil error, want permission error")
+	}
+}
+
+func TestService(t *testing.T) {
+	gopher := users.UserSpec{ID: 1, Domain: "example.org"}
+	repo := notifications.RepoSpec{URI: "github.com/a/b"}
+	hub := stream.NewHub(mockUsers{Current: gopher})
+	ms := &mockNotifications{hub: hub, subscribers: []users.UserSpec{gopher}}
+	s := stream.NewService(ms, hub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := hub.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Notify(context.Background(), repo, "Issue", 1, notifications.NotificationRequest{Title: "Hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := stream.Event{
+		Type:         stream.Notify,
+		Notification: notifications.Notification{RepoSpec: repo, ThreadType: "Issue", ThreadID: 1, Title: "Hello"},
+	}
+	if got := <-events; !reflect.DeepEqual(got, want) {
+		t.Errorf("got event %+v, want %+v", got, want)
+	}
+
+	err = s.MarkRead(ctx, repo, "Issue", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = stream.Event{
+		Type:         stream.Read,
+		Notification: notifications.Notification{RepoSpec: repo, ThreadType: "Issue", ThreadID: 1},
+	}
+	if got := <-events; !reflect.DeepEqual(got, want) {
+		t.Errorf("got event %+v, want %+v", got, want)
+	}
+
+	err = s.MarkUnread(ctx, repo, "Issue", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = stream.Event{
+		Type:         stream.Unread,
+		Notification: notifications.Notification{RepoSpec: repo, ThreadType: "Issue", ThreadID: 1, Title: "Hello"},
+	}
+	if got := <-events; !reflect.DeepEqual(got, want) {
+		t.Errorf("got event %+v, want %+v", got, want)
+	}
+}
+
+func TestServiceUndo(t *testing.T) {
+	gopher := users.UserSpec{ID: 1, Domain: "example.org"}
+	repo := notifications.RepoSpec{URI: "github.com/a/b"}
+	hub := stream.NewHub(mockUsers{Current: gopher})
+	ms := &mockNotifications{ns: notifications.Notifications{{RepoSpec: repo, ThreadType: "Issue", ThreadID: 1, Title: "Hello"}}}
+	s := undo.NewService(stream.NewService(ms, hub), mockUsers{Current: gopher})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := hub.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.MarkRead(ctx, repo, "Issue", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-events; got.Type != stream.Read {
+		t.Errorf("got event %+v, want %v event", got, stream.Read)
+	}
+	err = s.UndoMarkRead(ctx, []batch.Thread{{RepoURI: repo.URI, ThreadType: "Issue", ThreadID: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := stream.Event{
+		Type:         stream.Unread,
+		Notification: notifications.Notification{RepoSpec: repo, ThreadType: "Issue", ThreadID: 1, Title: "Hello"},
+	}
+	if got := <-events; !reflect.DeepEqual(got, want) {
+		t.Errorf("got event %+v, want %+v", got, want)
+	}
+}
+
+type mockNotifications struct {
+	notifications.Service
+	hub         *stream.Hub      // Hub that Notify publishes events to.
+	subscribers []users.UserSpec // Users that Notify notifies.
+
+	mu sync.Mutex
+	ns notifications.Notifications
+}
+
+func (m *mockNotifications) List(context.Context, notifications.ListOptions) (notifications.Notifications, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ns notifications.Notifications
+	for _, n := range m.ns {
+		if !n.Read {
+			ns = append(ns, n)
+		}
+	}
+	return ns, nil
+}
+
+func (m *mockNotifications) MarkRead(context.Context, notifications.RepoSpec, string, uint64) error {
+	m.setRead(true)
+	return nil
+}
+
+func (m *mockNotifications) MarkUnread(context.Context, notifications.RepoSpec, string, uint64) error {
+	m.setRead(false)
+	return nil
+}
+
+func (m *mockNotifications) setRead(read bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.ns {
+		m.ns[i].Read = read
+	}
+}
+
+// Notify creates a notification, and publishes a Notify event about it
+// to each subscriber, like a backend feeding a hub does.
+func (m *mockNotifications) Notify(_ context.Context, repo notifications.RepoSpec, threadType string, threadID uint64, nr notifications.NotificationRequest) error {
+	n := notifications.Notification{RepoSpec: repo, ThreadType: threadType, ThreadID: threadID, Title: nr.Title}
+	m.mu.Lock()
+	m.ns = append(m.ns, n)
+	m.mu.Unlock()
+	for _, u := range m.subscribers {
+		m.hub.Publish(u, stream.Event{Type: stream.Notify, Notification: n})
+	}
+	return nil
+}
+
+type mockUsers struct {
+	users.Service
+	Current users.UserSpec
+}
+
+func (m mockUsers) GetAuthenticatedSpec(context.Context) (users.UserSpec, error) {
+	return m.Current, nil
+}
@@ -0,0 +1,224 @@
+// Package undo contains an optional notifications.Service extension for undoing
+// mark as read operations, and a service that adds it to any notifications.Service.
+package undo
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/batch"
+	"github.com/shurcooL/notificationsapp/internal/authuser"
+	"github.com/shurcooL/notificationsapp/internal/unwrap"
+	"github.com/shurcooL/notificationsapp/unread"
+	"github.com/shurcooL/users"
+)
+
+// Undoer is an optional interface that a notifications.Service
+// can implement to support undoing mark as read operations.
+type Undoer interface {
+	// UndoMarkRead undoes the most recent mark as read operations of authenticated user
+	// on the specified threads. A thread with zero ThreadType and ThreadID refers to
+	// a MarkAllRead operation on the whole repository.
+	// Returns a not exist error if some operations can't be undone, e.g., because they're too old,
+	// after undoing the rest.
+	// Returns a permission error if no authenticated user.
+	UndoMarkRead(ctx context.Context, threads []batch.Thread) error
+}
+
+// Limits of the undo log of each user.
+const (
+	maxEntries = 100              // Maximum number of operations kept.
+	maxAge     = 10 * time.Minute // Maximum age of operations that can be undone.
+)
+
+// NewService creates a notifications service that keeps an undo log of mark as read
+// operations performed via it, and can undo them. It uses users service to determine
+// the authenticated user. The undo log is kept in memory.
+//
+// Undoing an operation marks the threads it marked as read as unread again,
+// so service or a service it wraps must implement unread.Marker.
+func NewService(service notifications.Service, users users.Service) *Service {
+	return &Service{
+		Service: service,
+		users:   users,
+		now:     time.Now,
+	}
+}
+
+// Service is a notifications service that adds undoing of mark as read operations to another one.
+type Service struct {
+	notifications.Service
+
+	users authuser.Getter
+	now   func() time.Time
+
+	mu     sync.Mutex
+	log    map[users.UserSpec][]entry // Undo log by user, oldest first.
+	lastID uint64                     // ID of the most recently recorded entry.
+}
+
+// entry is an entry in the undo log.
+type entry struct {
+	ID      uint64         // ID of the entry, unique within the undo log.
+	Op      batch.Thread   // Thread marked as read, or repository marked as read if zero ThreadType and ThreadID.
+	Threads []batch.Thread // Threads that got marked as read by the operation.
+	Time    time.Time      // Time of the operation.
+}
+
+// Unwrap returns the underlying notifications service.
+func (s *Service) Unwrap() notifications.Service { return s.Service }
+
+// MarkRead marks the specified thread as read, and records it in the undo log.
+//
+// The thread is recorded even if it was already read, since finding out would
+// require listing the notifications in repo on every call. Undoing it marks
+// the thread as unread; the frontend offers that only for unread threads.
+func (s *Service) MarkRead(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error {
+	user, err := authuser.Get(ctx, s.users)
+	if err != nil {
+		return err
+	}
+	err = s.Service.MarkRead(ctx, repo, threadType, threadID)
+	if err != nil {
+		return err
+	}
+	t := batch.Thread{RepoURI: repo.URI, ThreadType: threadType, ThreadID: threadID}
+	s.record(user, entry{Op: t, Threads: []batch.Thread{t}, Time: s.now()})
+	return nil
+}
+
+// MarkAllRead marks all notifications in the specified repository as read,
+// and records it in the undo log. The threads in repo that are unread
+// are listed beforehand, so that undoing it marks only them as unread.
+func (s *Service) MarkAllRead(ctx context.Context, repo notifications.RepoSpec) error {
+	user, err := authuser.Get(ctx, s.users)
+	if err != nil {
+		return err
+	}
+	ns, err := s.Service.List(ctx, notifications.ListOptions{Repo: &repo})
+	if err != nil {
+		return err
+	}
+	err = s.Service.MarkAllRead(ctx, repo)
+	if err != nil {
+		return err
+	}
+	e := entry{Op: batch.Thread{RepoURI: repo.URI}, Time: s.now()}
+	for _, n := range ns {
+		if n.Read {
+			continue
+		}
+		e.Threads = append(e.Threads, batch.Thread{RepoURI: n.RepoSpec.URI, ThreadType: n.ThreadType, ThreadID: n.ThreadID})
+	}
+	s.record(user, e)
+	return nil
+}
+
+// MarkReadBatch implements batch.MarkReader. It records each thread
+// that got marked as read in the undo log, the same way MarkRead does.
+func (s *Service) MarkReadBatch(ctx context.Context, threads []batch.Thread) ([]batch.Failure, error) {
+	user, err := authuser.Get(ctx, s.users)
+	if err != nil {
+		return nil, err
+	}
+	failures, err := batch.MarkRead(ctx, s.Service, threads)
+	if err != nil {
+		return nil, err
+	}
+	failed := make(map[batch.Thread]bool, len(failures))
+	for _, f := range failures {
+		failed[f.Thread] = true
+	}
+	now := s.now()
+	for _, t := range threads {
+		if failed[t] {
+			continue
+		}
+		s.record(user, entry{Op: t, Threads: []batch.Thread{t}, Time: now})
+	}
+	return failures, nil
+}
+
+// UndoMarkRead implements Undoer. An operation is removed from the undo log
+// only once it's undone, so it can be retried if undoing it fails.
+// It returns unread.ErrNotSupported if no underlying service implements unread.Marker.
+func (s *Service) UndoMarkRead(ctx context.Context, threads []batch.Thread) error {
+	m, ok := unwrap.Find[unread.Marker](s.Service)
+	if !ok {
+		return unread.ErrNotSupported
+	}
+	user, err := authuser.Get(ctx, s.users)
+	if err != nil {
+		return err
+	}
+	notFound := false
+	for _, t := range threads {
+		e, ok := s.find(user, t)
+		if !ok {
+			notFound = true
+			continue
+		}
+		for _, t := range e.Threads {
+			err := m.MarkUnread(ctx, notifications.RepoSpec{URI: t.RepoURI}, t.ThreadType, t.ThreadID)
+			if err != nil {
+				return err
+			}
+		}
+		s.remove(user, e.ID)
+	}
+	if notFound {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+// record records entry e in the undo log of user, assigning it an ID,
+// and drops entries that are past the limits of the log.
+func (s *Service) record(user users.UserSpec, e entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		s.log = make(map[users.UserSpec][]entry)
+	}
+	s.lastID++
+	e.ID = s.lastID
+	log := append(s.log[user], e)
+	for len(log) > 0 && (len(log) > maxEntries || e.Time.Sub(log[0].Time) > maxAge) {
+		log = log[1:]
+	}
+	s.log[user] = log
+}
+
+// find returns the most recent entry for operation op in the undo log of user.
+// It reports false if there's no such entry that can be undone.
+func (s *Service) find(user users.UserSpec, op batch.Thread) (entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log := s.log[user]
+	for i := len(log) - 1; i >= 0; i-- {
+		if log[i].Op != op {
+			continue
+		}
+		if s.now().Sub(log[i].Time) > maxAge {
+			return entry{}, false
+		}
+		return log[i], true
+	}
+	return entry{}, false
+}
+
+// remove removes the entry with the specified ID from the undo log of user, if it's there.
+func (s *Service) remove(user users.UserSpec, id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log := s.log[user]
+	for i := range log {
+		if log[i].ID == id {
+			s.log[user] = append(log[:i:i], log[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package undo_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/batch"
+	"github.com/shurcooL/notificationsapp/undo"
+	"github.com/shurcooL/users"
+)
+
+func TestService(t *testing.T) {
+	repo := notifications.RepoSpec{URI: "github.com/a/b"}
+	ms := &mockNotifications{ns: notifications.Notifications{
+		{RepoSpec: repo, ThreadType: "Issue", ThreadID: 1},
+		{RepoSpec: repo, ThreadType: "Issue", ThreadID: 2},
+		{RepoSpec: repo, ThreadType: "Issue", ThreadID: 3, Read: true},
+	}}
+	s := undo.NewService(ms, mockUsers{Current: users.UserSpec{ID: 1, Domain: "example.org"}})
+	ctx := context.Background()
+
+	err := s.MarkAllRead(ctx, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ms.unreadIDs(), []uint64(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("after MarkAllRead: got unread threads %v, want %v", got, want)
+	}
+	err = s.UndoMarkRead(ctx, []batch.Thread{{RepoURI: repo.URI}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ms.unreadIDs(), []uint64{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after UndoMarkRead: got unread threads %v, want %v", got, want)
+	}
+
+	// Each operation can be undone only once.
+	err = s.UndoMarkRead(ctx, []batch.Thread{{RepoURI: repo.URI}})
+	if !os.IsNotExist(err) {
+		t.Errorf("got error %v, want not exist error", err)
+	}
+
+	err = s.MarkRead(ctx, repo, "Issue", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.UndoMarkRead(ctx, []batch.Thread{{RepoURI: repo.URI, ThreadType: "Issue", ThreadID: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ms.unreadIDs(), []uint64{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after UndoMarkRead: got unread threads %v, want %v", got, want)
+	}
+}
+
+func TestServiceRetry(t *testing.T) {
+	repo := notifications.RepoSpec{URI: "github.com/a/b"}
+	ms := &mockNotifications{ns: notifications.Notifications{
+		{RepoSpec: repo, ThreadType: "Issue", ThreadID: 1},
+	}}
+	s := undo.NewService(ms, mockUsers{Current: users.UserSpec{ID: 1, Domain: "example.org"}})
+	ctx := context.Background()
+
+	err := s.MarkRead(ctx, repo, "Issue", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ms.lists, 0; got != want {
+		t.Errorf("MarkRead made %v List calls, want %v", got, want)
+	}
+
+	// A failed undo can be retried.
+	op := []batch.Thread{{RepoURI: repo.URI, ThreadType: "Issue", ThreadID: 1}}
+	ms.unreadErr = errors.New("temporary failure")
+	err = s.UndoMarkRead(ctx, op)
+	if err != ms.unreadErr {
+		t.Errorf("got error %v, want %v", err, ms.unreadErr)
+	}
+	ms.unreadErr = nil
+	err = s.UndoMarkRead(ctx, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ms.unreadIDs(), []uint64{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after UndoMarkRead: got unread threads %v, want %v", got, want)
+	}
+}
+
+type mockNotifications struct {
+	notifications.ExternalService
+	ns        notifications.Notifications
+	lists     int   // Number of List calls.
+	unreadErr error // Error to return from MarkUnread.
+}
+
+func (m *mockNotifications) List(_ context.Context, opt notifications.ListOptions) (notifications.Notifications, error) {
+	m.lists++
+	var ns notifications.Notifications
+	for _, n := range m.ns {
+		if n.Read && !opt.All {
+			continue
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
+
+func (m *mockNotifications) Count(context.Context, interface{}) (uint64, error) {
+	return uint64(len(m.unreadIDs())), nil
+}
+
+func (m *mockNotifications) MarkRead(_ context.Context, _ notifications.RepoSpec, _ string, threadID uint64) error {
+	m.setRead(func(n notifications.Notification) bool { return n.ThreadID == threadID }, true)
+	return nil
+}
+
+func (m *mockNotifications) MarkAllRead(context.Context, notifications.RepoSpec) error {
+	m.setRead(func(notifications.Notification) bool { return true }, true)
+	return nil
+}
+
+func (m *mockNotifications) MarkUnread(_ context.Context, _ notifications.RepoSpec, _ string, threadID uint64) error {
+	if m.unreadErr != nil {
+		return m.unreadErr
+	}
+	m.setRead(func(n notifications.Notification) bool { return n.ThreadID == threadID }, false)
+	return nil
+}
+
+func (m *mockNotifications) setRead(match func(notifications.Notification) bool, read bool) {
+	for i := range m.ns {
+		if match(m.ns[i]) {
+			m.ns[i].Read = read
+		}
+	}
+}
+
+func (m *mockNotifications) unreadIDs() []uint64 {
+	var ids []uint64
+	for _, n := range m.ns {
+		if !n.Read {
+			ids = append(ids, n.ThreadID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+type mockUsers struct {
+	users.Service
+	Current users.UserSpec
+}
+
+func (m mockUsers) GetAuthenticatedSpec(context.Context) (users.UserSpec, error) {
+	return m.Current, nil
+}
@@ -0,0 +1,26 @@
+// Package unread contains an optional notifications.Service extension for marking
+// threads as unread.
+package unread
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shurcooL/notifications"
+)
+
+// Marker is an optional interface that a notifications.Service
+// can implement to support marking threads as unread.
+type Marker interface {
+	// MarkUnread marks the specified thread as unread.
+	// It undoes the effect of MarkRead.
+	// Returns a permission error if no authenticated user.
+	MarkUnread(ctx context.Context, repo notifications.RepoSpec, threadType string, threadID uint64) error
+}
+
+// ErrNotSupported is returned by MarkUnread implementations that wrap
+// a notifications.Service which doesn't support marking threads as unread.
+// Such implementations should have a Forwards(target any) bool method that
+// reports true for a *Marker target, so that they're considered to support
+// marking threads as unread only if the service they wrap does.
+var ErrNotSupported = errors.New("marking as unread is not supported")
@@ -0,0 +1,192 @@
+// Package validator computes HTTP cache validators for notifications,
+// and evaluates conditional requests against them.
+package validator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shurcooL/notifications"
+)
+
+// ETag returns a strong entity tag for the representation kind of notifications ns.
+// kind distinguishes different representations of the same notifications,
+// e.g., "html" or "json". The entity tag is computed from the thread identities,
+// Read flags and UpdatedAt times of ns.
+func ETag(kind string, ns notifications.Notifications) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q\n", kind)
+	for _, n := range ns {
+		fmt.Fprintf(h, "%q %q %d %t %d\n", n.RepoSpec.URI, n.ThreadType, n.ThreadID, n.Read, n.UpdatedAt.UnixNano())
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// WeakETag returns a weak entity tag for the representation kind of notifications ns.
+// It's for representations that also depend on the current time, such as
+// HTML pages that display relative times, e.g., "3 hours ago".
+func WeakETag(kind string, ns notifications.Notifications) string {
+	return "W/" + ETag(kind, ns)
+}
+
+// CountETag returns a strong entity tag for a notification count.
+func CountETag(count uint64) string {
+	return fmt.Sprintf(`"count-%d"`, count)
+}
+
+// LastModified returns the latest UpdatedAt time of notifications ns,
+// or zero time if ns is empty.
+//
+// Marking a notification as read or unread doesn't change its UpdatedAt time,
+// so it's not suitable as a validator of lists of notifications.
+func LastModified(ns notifications.Notifications) time.Time {
+	var t time.Time
+	for _, n := range ns {
+		if n.UpdatedAt.After(t) {
+			t = n.UpdatedAt
+		}
+	}
+	return t
+}
+
+// NotModified sets the ETag and Last-Modified headers of w, and evaluates
+// the conditional headers of req against them, as specified in RFC 7232.
+// If-Modified-Since is only evaluated if req doesn't have If-None-Match.
+//
+// The Last-Modified time is when the representation of the resource
+// last changed to one with entity tag etag. It's used instead of
+// the UpdatedAt times of notifications, since those don't change
+// when notifications are marked as read or unread. See lastModified.
+//
+// If the representation cached by the client is current, NotModified writes
+// a 304 Not Modified response and reports true. The caller must not write
+// a body in that case.
+//
+// Responses are marked as private and requiring revalidation,
+// since notifications are specific to the user and change often.
+func NotModified(w http.ResponseWriter, req *http.Request, etag string) bool {
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", etag)
+	modified, exact := lastModified(req, etag)
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return false
+	}
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		if !matchETag(inm, etag) {
+			return false
+		}
+	} else if !exact || !notModifiedSince(req.Header.Get("If-Modified-Since"), modified) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// notModifiedSince reports whether the value of an If-Modified-Since header
+// is a valid date that's not before modified.
+func notModifiedSince(ifModifiedSince string, modified time.Time) bool {
+	if ifModifiedSince == "" {
+		return false
+	}
+	t, err := http.ParseTime(ifModifiedSince)
+	if err != nil || t.After(time.Now()) {
+		// Dates later than the current time are invalid, per RFC 7232.
+		return false
+	}
+	return !t.Before(modified)
+}
+
+// modTimes are the Last-Modified times of representations served
+// by this process, keyed by representationKey.
+var modTimes = struct {
+	sync.Mutex
+	m     map[string]modTime
+	floor time.Time // Later than all forgotten Last-Modified times.
+}{m: make(map[string]modTime)}
+
+// maxModTimes is the number of Last-Modified times remembered,
+// after which they're forgotten.
+const maxModTimes = 10000
+
+// modTime is when a representation changed to have entity tag etag.
+type modTime struct {
+	etag string
+	at   time.Time // Truncated to seconds, the precision of HTTP dates.
+
+	// exact reports whether at is later than the previous Last-Modified
+	// time of the representation. If it isn't, a client could have
+	// an earlier representation that has the same Last-Modified time,
+	// so If-Modified-Since can't be relied on.
+	exact bool
+}
+
+// lastModified returns the Last-Modified time of the representation
+// with entity tag etag that's requested by req, and whether it can be
+// used to evaluate If-Modified-Since.
+//
+// Since there's no record of when the read state of a notification
+// changed, it's the time when this process first served the representation
+// with entity tag etag, after having served a different one. Entity tags
+// reflect read state, so marking a notification as read or unread changes it.
+// The times are kept in memory, so they're reset when the process restarts.
+func lastModified(req *http.Request, etag string) (time.Time, bool) {
+	key := representationKey(req)
+	now := time.Now().UTC().Truncate(time.Second)
+	modTimes.Lock()
+	defer modTimes.Unlock()
+	mt, ok := modTimes.m[key]
+	if ok && mt.etag == etag {
+		return mt.at, mt.exact
+	}
+	prev := modTimes.floor
+	if ok {
+		prev = mt.at
+	}
+	if len(modTimes.m) >= maxModTimes {
+		for _, mt := range modTimes.m {
+			if mt.at.After(modTimes.floor) {
+				modTimes.floor = mt.at
+			}
+		}
+		modTimes.m = make(map[string]modTime)
+	}
+	mt = modTime{etag: etag, at: now, exact: now.After(prev)}
+	modTimes.m[key] = mt
+	return mt.at, mt.exact
+}
+
+// representationKey returns a key that identifies the representation
+// requested by req, including the user who requested it. Requests
+// for different representations may have the same key; that only
+// makes If-Modified-Since less effective.
+func representationKey(req *http.Request) string {
+	uri := req.RequestURI
+	if uri == "" {
+		uri = req.URL.RequestURI()
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%q %q\n", req.Host, uri)
+	for _, name := range []string{"Accept", "Accept-Language", "Authorization", "Cookie"} {
+		fmt.Fprintf(h, "%q\n", req.Header.Values(name))
+	}
+	return string(h.Sum(nil))
+}
+
+// matchETag reports whether the value of an If-None-Match header
+// matches etag, using the weak comparison function.
+func matchETag(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package validator_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shurcooL/notifications"
+	"github.com/shurcooL/notificationsapp/validator"
+)
+
+func TestETag(t *testing.T) {
+	ns := notifications.Notifications{
+		{RepoSpec: notifications.RepoSpec{URI: "example.org/repo"}, ThreadType: "Issue", ThreadID: 1, UpdatedAt: time.Unix(1, 0)},
+	}
+	etag := validator.ETag("json", ns)
+	if got := validator.ETag("json", ns); got != etag {
+		t.Errorf("ETag isn't deterministic: got %v, want %v", got, etag)
+	}
+	if got := validator.ETag("html", ns); got == etag {
+		t.Error("ETag of different representations should differ")
+	}
+	if got, want := validator.WeakETag("html", ns), "W/"+validator.ETag("html", ns); got != want {
+		t.Errorf("WeakETag: got %v, want %v", got, want)
+	}
+	ns[0].Read = true
+	if got := validator.ETag("json", ns); got == etag {
+		t.Error("ETag should change when a notification is marked as read")
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		header http.Header
+		want   bool
+	}{
+		{header: http.Header{}, want: false},
+		{header: http.Header{"If-None-Match": {`"abc"`}}, want: true},
+		{header: http.Header{"If-None-Match": {`"xyz", W/"abc"`}}, want: true},
+		{header: http.Header{"If-None-Match": {"*"}}, want: true},
+		{header: http.Header{"If-None-Match": {`"xyz"`}}, want: false},
+		{header: http.Header{"If-Modified-Since": {time.Unix(0, 0).UTC().Format(http.TimeFormat)}}, want: false},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header = tc.header
+		w := httptest.NewRecorder()
+		got := validator.NotModified(w, req, etag)
+		if got != tc.want {
+			t.Errorf("%v: got %v, want %v", tc.header, got, tc.want)
+		}
+		if got && w.Code != http.StatusNotModified {
+			t.Errorf("%v: got status %v, want %v", tc.header, w.Code, http.StatusNotModified)
+		}
+		if h := w.Header().Get("ETag"); h != etag {
+			t.Errorf("%v: got ETag header %q, want %q", tc.header, h, etag)
+		}
+	}
+}
+
+func TestNotModifiedSince(t *testing.T) {
+	get := func(etag string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/since", nil)
+		req.Header = header
+		w := httptest.NewRecorder()
+		validator.NotModified(w, req, etag)
+		return w
+	}
+
+	lastModified := get(`"a"`, http.Header{}).Header().Get("Last-Modified")
+	if lastModified == "" {
+		t.Fatal("got no Last-Modified header")
+	}
+	if w := get(`"a"`, http.Header{"If-Modified-Since": {lastModified}}); w.Code != http.StatusNotModified {
+		t.Errorf("unchanged: got status %v, want %v", w.Code, http.StatusNotModified)
+	}
+	if w := get(`"a"`, http.Header{"If-Modified-Since": {lastModified}, "If-None-Match": {`"b"`}}); w.Code != http.StatusOK {
+		t.Errorf("If-None-Match present: got status %v, want %v", w.Code, http.StatusOK)
+	}
+
+	// Marking a notification as read changes the entity tag, but not UpdatedAt times.
+	if w := get(`"b"`, http.Header{"If-Modified-Since": {lastModified}}); w.Code != http.StatusOK {
+		t.Errorf("changed: got status %v, want %v", w.Code, http.StatusOK)
+	}
+	if w := get(`"a"`, http.Header{"If-Modified-Since": {lastModified}}); w.Code != http.StatusOK {
+		t.Errorf("changed back: got status %v, want %v", w.Code, http.StatusOK)
+	}
+}